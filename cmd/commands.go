@@ -2,17 +2,24 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/tejzpr/commitmonk/config"
 	"github.com/tejzpr/commitmonk/db"
+	"github.com/tejzpr/commitmonk/git"
+	"github.com/tejzpr/commitmonk/llm"
+	"github.com/tejzpr/commitmonk/repoconfig"
 	"github.com/tejzpr/commitmonk/scheduler"
+	"github.com/tejzpr/commitmonk/secrets"
 	"github.com/urfave/cli/v2"
 )
 
@@ -34,8 +41,15 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 			&cli.StringFlag{
 				Name:    "every",
 				Aliases: []string{"e"},
-				Usage:   "Commit interval (>=1m, default: 5m)",
-				Value:   cfg.DefaultInterval,
+				Usage:   "Commit interval (>=1m, default: default.interval from config)",
+			},
+			&cli.StringFlag{
+				Name:  "cron",
+				Usage: "Cron schedule instead of an interval (e.g. \"*/15 9-17 * * 1-5\", \"0 18 * * *\", \"@hourly\")",
+			},
+			&cli.StringFlag{
+				Name:  "tz",
+				Usage: "IANA time zone for the cron schedule (default: local time)",
 			},
 			&cli.StringFlag{
 				Name:    "message",
@@ -46,6 +60,68 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 				Name:  "exclude",
 				Usage: "Comma-separated list of glob patterns to ignore",
 			},
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Commit after file changes settle instead of on a fixed interval (--every becomes the maximum delay)",
+			},
+			&cli.StringFlag{
+				Name:  "overlap",
+				Usage: "What to do when a run comes due while the previous one is still in progress (skip or queue)",
+				Value: db.OverlapSkip,
+			},
+			&cli.StringFlag{
+				Name:  "settle",
+				Usage: "Quiet period to wait after the last change in watch mode",
+				Value: "30s",
+			},
+			&cli.StringFlag{
+				Name:  "prompt-template",
+				Usage: "Go text/template file for the LLM prompt (default: llm.prompt_template from config)",
+			},
+			&cli.StringFlag{
+				Name:  "fallback",
+				Usage: "Without an LLM message or --message, commit with a message derived from the changes (heuristic) or not at all (none)",
+				Value: db.FallbackHeuristic,
+			},
+			&cli.BoolFlag{
+				Name:  "no-llm-on-secrets",
+				Usage: "Do not send diffs containing secrets to the LLM, even redacted",
+			},
+			&cli.StringFlag{
+				Name:  "secret-policy",
+				Usage: "When staged changes introduce secrets: refuse to commit (block), commit without the affected files (unstage), or don't scan (off)",
+				Value: db.SecretPolicyBlock,
+			},
+			&cli.StringFlag{
+				Name:  "author",
+				Usage: "Commit author as \"Name <email>\" (default: user.name and user.email from git config)",
+			},
+			&cli.StringFlag{
+				Name:  "attribution",
+				Usage: "Credit commitmonk in commits as a Co-authored-by trailer (co-author), as the committer (committer), or not at all (none)",
+				Value: db.AttributionNone,
+			},
+			&cli.BoolFlag{
+				Name:  "snapshot",
+				Usage: "Commit snapshots to refs/commitmonk/<branch> instead of the checked-out branch, leaving HEAD and the index untouched",
+			},
+			&cli.StringFlag{
+				Name:  "sync",
+				Usage: "When the remote branch has moved on before an auto-push: keep the commit local (fail-fast), rebase onto it (rebase), or merge it (merge)",
+				Value: db.SyncFailFast,
+			},
+			&cli.StringFlag{
+				Name:  "push-remote",
+				Usage: "Remote to auto-push to (default: the remote git push would use, or origin)",
+			},
+			&cli.StringFlag{
+				Name:  "push-branch",
+				Usage: "Remote branch to auto-push to (default: the upstream branch, or the local branch's name)",
+			},
+			&cli.StringFlag{
+				Name:  "mirror",
+				Usage: "Comma-separated remotes to also auto-push to",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			if c.NArg() != 1 {
@@ -64,20 +140,126 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 				return fmt.Errorf("%s is not a git repository", absPath)
 			}
 
-			// Validate interval
+			// Validate schedule: either a cron expression or an interval
 			interval := c.String("every")
-			duration, err := time.ParseDuration(interval)
-			if err != nil {
-				return fmt.Errorf("invalid interval format: %w", err)
+			if interval == "" {
+				interval = cfg.DefaultInterval
+			}
+			cronExpr := c.String("cron")
+			timezone := c.String("tz")
+			var duration time.Duration
+			if cronExpr != "" {
+				if c.IsSet("every") {
+					return fmt.Errorf("--every and --cron cannot be used together")
+				}
+				if c.Bool("watch") {
+					return fmt.Errorf("--watch cannot be used with --cron")
+				}
+				if _, err := config.ParseCron(cronExpr, timezone); err != nil {
+					return err
+				}
+				interval = ""
+			} else {
+				if timezone != "" {
+					return fmt.Errorf("--tz can only be used with --cron")
+				}
+				if err := config.ValidateInterval(interval); err != nil {
+					return err
+				}
+				duration, _ = time.ParseDuration(interval)
+			}
+
+			// Validate settle window for watch mode
+			settle := ""
+			if c.Bool("watch") {
+				settle = c.String("settle")
+				settleDuration, err := time.ParseDuration(settle)
+				if err != nil {
+					return fmt.Errorf("invalid settle format: %w", err)
+				}
+				if settleDuration < time.Second {
+					return fmt.Errorf("settle window must be at least 1 second")
+				}
+				if settleDuration > duration {
+					return fmt.Errorf("settle window must not exceed the commit interval")
+				}
+			}
+
+			// Validate overlap policy
+			overlap := c.String("overlap")
+			if overlap != db.OverlapSkip && overlap != db.OverlapQueue {
+				return fmt.Errorf("invalid overlap policy %q: must be %s or %s", overlap, db.OverlapSkip, db.OverlapQueue)
+			}
+
+			// Validate secret policy
+			secretPolicy := c.String("secret-policy")
+			if secretPolicy != db.SecretPolicyBlock && secretPolicy != db.SecretPolicyUnstage && secretPolicy != db.SecretPolicyOff {
+				return fmt.Errorf("invalid secret policy %q: must be %s, %s or %s", secretPolicy, db.SecretPolicyBlock, db.SecretPolicyUnstage, db.SecretPolicyOff)
+			}
+
+			// Validate commit attribution
+			author := c.String("author")
+			if author != "" {
+				if _, err := git.ParseIdentity(author); err != nil {
+					return err
+				}
+			}
+			attribution := c.String("attribution")
+			if attribution != db.AttributionNone && attribution != db.AttributionCoAuthor && attribution != db.AttributionCommitter {
+				return fmt.Errorf("invalid attribution %q: must be %s, %s or %s", attribution, db.AttributionNone, db.AttributionCoAuthor, db.AttributionCommitter)
+			}
+
+			// Validate sync strategy
+			syncStrategy := c.String("sync")
+			if syncStrategy != db.SyncFailFast && syncStrategy != db.SyncRebase && syncStrategy != db.SyncMerge {
+				return fmt.Errorf("invalid sync strategy %q: must be %s, %s or %s", syncStrategy, db.SyncFailFast, db.SyncRebase, db.SyncMerge)
 			}
-			if duration < time.Minute {
-				return fmt.Errorf("interval must be at least 1 minute")
+
+			// Validate push remotes, which must already be configured
+			pushRemote := c.String("push-remote")
+			mirrors := c.String("mirror")
+			if pushRemote != "" || mirrors != "" {
+				repoManager, err := git.NewRepoManager(absPath)
+				if err != nil {
+					return err
+				}
+				for _, remote := range append([]string{pushRemote}, strings.Split(mirrors, ",")...) {
+					if remote = strings.TrimSpace(remote); remote == "" {
+						continue
+					}
+					exists, err := repoManager.HasRemote(remote)
+					if err != nil {
+						return err
+					}
+					if !exists {
+						return fmt.Errorf("remote %q is not configured in %s", remote, absPath)
+					}
+				}
+			}
+
+			// Validate fallback
+			fallback := c.String("fallback")
+			if fallback != db.FallbackHeuristic && fallback != db.FallbackNone {
+				return fmt.Errorf("invalid fallback %q: must be %s or %s", fallback, db.FallbackHeuristic, db.FallbackNone)
 			}
 
 			// Check if message is required
 			staticMsg := c.String("message")
-			if staticMsg == "" && cfg.LLM.APIKey == "" {
-				return fmt.Errorf("commit message is required when LLM is not configured. Use --message to provide one")
+			if staticMsg == "" && !cfg.LLM.AnyConfigured() && fallback == db.FallbackNone {
+				return fmt.Errorf("commit message is required when LLM is not configured and the fallback is disabled. Use --message to provide one")
+			}
+
+			// Validate prompt template; store it absolute since runs happen elsewhere
+			promptTemplate := c.String("prompt-template")
+			if promptTemplate != "" {
+				if !strings.HasPrefix(promptTemplate, "~/") {
+					if promptTemplate, err = filepath.Abs(promptTemplate); err != nil {
+						return fmt.Errorf("failed to get absolute path: %w", err)
+					}
+				}
+				if _, err := llm.LoadPromptTemplate(promptTemplate); err != nil {
+					return err
+				}
 			}
 
 			// Create task - note the negation of no-autoadd flag
@@ -88,6 +270,23 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 				AutoPush:        c.Bool("autopush"),
 				StaticMsg:       staticMsg,
 				ExcludePatterns: c.String("exclude"),
+				Watch:           c.Bool("watch"),
+				Settle:          settle,
+				Cron:            cronExpr,
+				Timezone:        timezone,
+				Overlap:         overlap,
+				PromptTemplate:  promptTemplate,
+				Fallback:        fallback,
+
+				SkipLLMOnSecrets: c.Bool("no-llm-on-secrets"),
+				SecretPolicy:     secretPolicy,
+				Author:           author,
+				Attribution:      attribution,
+				Snapshot:         c.Bool("snapshot"),
+				SyncStrategy:     syncStrategy,
+				PushRemote:       pushRemote,
+				PushBranch:       c.String("push-branch"),
+				Mirrors:          mirrors,
 			}
 
 			// Add to database
@@ -95,7 +294,7 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 				return fmt.Errorf("failed to register repository: %w", err)
 			}
 
-			fmt.Printf("Registered %s (every %s", absPath, interval)
+			fmt.Printf("Registered %s (%s", absPath, scheduler.DescribeSchedule(task))
 			// Update display to reflect the changed default behavior
 			if !task.AutoAdd {
 				fmt.Print(", auto-add disabled")
@@ -109,8 +308,53 @@ func AddCommand(database *db.DB, cfg *config.Config) *cli.Command {
 			if task.ExcludePatterns != "" {
 				fmt.Printf(", exclude=%s", task.ExcludePatterns)
 			}
+			if task.Watch {
+				fmt.Printf(", watch mode, settle %s", task.Settle)
+			}
+			if task.Overlap == db.OverlapQueue {
+				fmt.Print(", queue overlapping runs")
+			}
+			if task.PromptTemplate != "" {
+				fmt.Printf(", prompt template %s", task.PromptTemplate)
+			}
+			if task.Fallback == db.FallbackNone {
+				fmt.Print(", no fallback message")
+			}
+			if task.SkipLLMOnSecrets {
+				fmt.Print(", no LLM on secrets")
+			}
+			if task.SecretPolicy != db.SecretPolicyBlock {
+				fmt.Printf(", secret policy %s", task.SecretPolicy)
+			}
+			if task.Author != "" {
+				fmt.Printf(", author %s", task.Author)
+			}
+			if task.Attribution != db.AttributionNone {
+				fmt.Printf(", commitmonk as %s", task.Attribution)
+			}
+			if task.Snapshot {
+				fmt.Print(", snapshot mode")
+			}
+			if task.SyncStrategy != db.SyncFailFast {
+				fmt.Printf(", %s before push", task.SyncStrategy)
+			}
+			if task.PushRemote != "" || task.PushBranch != "" {
+				fmt.Printf(", push to %s", describePushTarget(task))
+			}
+			if task.Mirrors != "" {
+				fmt.Printf(", mirror to %s", task.Mirrors)
+			}
 			fmt.Println(")")
 
+			// Commits fall back to commitmonk's own identity without a git one
+			if task.Author == "" {
+				if repoManager, err := git.NewRepoManager(absPath); err == nil {
+					if identity, err := repoManager.ConfiguredIdentity(c.Context); err == nil && identity == git.BotIdentity {
+						fmt.Printf("Warning: user.name and user.email are not set in git config, commits will be authored by %s\n", identity)
+					}
+				}
+			}
+
 			return nil
 		},
 	}
@@ -176,7 +420,10 @@ func ListCommand(database *db.DB) *cli.Command {
 
 			fmt.Println("Registered repositories:")
 			for _, task := range tasks {
-				fmt.Printf("[ID: %d] %s (every %s", task.ID, task.Path, task.Every)
+				// Show the settings the scheduler uses after the repository's config file
+				task, repoFile, repoFileErr := scheduler.EffectiveTask(task)
+
+				fmt.Printf("[ID: %d] %s (%s", task.ID, task.Path, scheduler.DescribeSchedule(task))
 				if task.AutoAdd {
 					fmt.Print(", auto-add enabled")
 				} else {
@@ -191,6 +438,53 @@ func ListCommand(database *db.DB) *cli.Command {
 				if task.ExcludePatterns != "" {
 					fmt.Printf(", exclude=%s", task.ExcludePatterns)
 				}
+				if task.Overlap == db.OverlapQueue {
+					fmt.Print(", queue overlapping runs")
+				}
+				if task.PromptTemplate != "" {
+					fmt.Printf(", prompt template %s", task.PromptTemplate)
+				}
+				if task.Fallback == db.FallbackNone {
+					fmt.Print(", no fallback message")
+				}
+				if task.SkipLLMOnSecrets {
+					fmt.Print(", no LLM on secrets")
+				}
+				if task.SecretPolicy != db.SecretPolicyBlock {
+					fmt.Printf(", secret policy %s", task.SecretPolicy)
+				}
+				if task.Author != "" {
+					fmt.Printf(", author %s", task.Author)
+				}
+				if task.Attribution != db.AttributionNone {
+					fmt.Printf(", commitmonk as %s", task.Attribution)
+				}
+				if task.Snapshot {
+					fmt.Print(", snapshot mode")
+				}
+				if task.SyncStrategy != db.SyncFailFast {
+					fmt.Printf(", %s before push", task.SyncStrategy)
+				}
+				if task.PushRemote != "" || task.PushBranch != "" {
+					fmt.Printf(", push to %s", describePushTarget(task))
+				}
+				if task.Mirrors != "" {
+					fmt.Printf(", mirror to %s", task.Mirrors)
+				}
+				if repoFileErr != nil {
+					fmt.Printf(", repo config ignored: %v", repoFileErr)
+				} else if repoFile != nil {
+					fmt.Printf(", overridden by %s", repoFile.Name())
+				}
+				if task.Watch {
+					fmt.Printf(", watch mode, settle %s", task.Settle)
+				} else if task.Cron != "" {
+					if nextRun, err := scheduler.NextRun(task, time.Now()); err == nil {
+						fmt.Printf(", next run %s", nextRun.Format("2006-01-02 15:04:05 MST"))
+					}
+				} else if nextRun, ok := estimateNextRun(database, task); ok {
+					fmt.Printf(", next run about %s", nextRun.Format("2006-01-02 15:04:05 MST"))
+				}
 				fmt.Println(")")
 			}
 
@@ -204,57 +498,277 @@ func ConfigCommand(cfg *config.Config) *cli.Command {
 	return &cli.Command{
 		Name:  "config",
 		Usage: "Configure default settings and LLM credentials",
+		Subcommands: []*cli.Command{
+			configGetCommand(cfg),
+			configSetCommand(cfg),
+			configUnsetCommand(cfg),
+			configListCommand(cfg),
+			configShowCommand(cfg),
+		},
 		Action: func(c *cli.Context) error {
 			scanner := bufio.NewScanner(os.Stdin)
 
-			// Configure default interval
-			fmt.Printf("Default commit interval (current: %s): ", cfg.DefaultInterval)
-			scanner.Scan()
-			input := strings.TrimSpace(scanner.Text())
-			if input != "" {
-				duration, err := time.ParseDuration(input)
+			for _, prompt := range configPrompts {
+				key, err := config.LookupKey(prompt.key)
 				if err != nil {
-					return fmt.Errorf("invalid interval format: %w", err)
+					return err
+				}
+
+				current := key.Get(cfg)
+				if key.Secret {
+					current = maskAPIKey(current)
+				}
+				if key.Name == "llm.api_key" && cfg.LLM.APIKeySource() != "" {
+					current = cfg.LLM.APIKeySource()
 				}
-				if duration < time.Minute {
-					return fmt.Errorf("interval must be at least 1 minute")
+				fmt.Printf("%s (current: %s): ", prompt.label, current)
+				scanner.Scan()
+				input := strings.TrimSpace(scanner.Text())
+				if input != "" {
+					if err := key.Set(cfg, input); err != nil {
+						return err
+					}
 				}
-				cfg.DefaultInterval = input
 			}
 
-			// Configure LLM
-			fmt.Printf("OpenAI-compatible API Base URL (current: %s): ", cfg.LLM.BaseURL)
-			scanner.Scan()
-			input = strings.TrimSpace(scanner.Text())
-			if input != "" {
-				cfg.LLM.BaseURL = input
+			// Offer to move a plaintext API key out of the config file
+			if cfg.LLM.APIKey != "" && cfg.Source("llm.api_key") != config.SourceDefault &&
+				!strings.HasPrefix(cfg.Source("llm.api_key"), config.SourceEnv) && config.KeyringAvailable() {
+				fmt.Print("Move the API key from the config file to the system keyring? [y/N]: ")
+				scanner.Scan()
+				answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+				if answer == "y" || answer == "yes" {
+					if err := migrateAPIKeyToKeyring(c.Context, cfg); err != nil {
+						return err
+					}
+					fmt.Println("API key moved to the system keyring")
+				}
+			}
+
+			// Save configuration
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save configuration: %w", err)
 			}
 
-			fmt.Printf("API key (current: %s): ", maskAPIKey(cfg.LLM.APIKey))
-			scanner.Scan()
-			input = strings.TrimSpace(scanner.Text())
-			if input != "" {
-				cfg.LLM.APIKey = input
+			fmt.Println("Configuration saved successfully")
+			return nil
+		},
+	}
+}
+
+// apiKeyKeyringAccount is the keyring account used when migrating a plaintext API key
+const apiKeyKeyringAccount = "llm.api_key"
+
+// migrateAPIKeyToKeyring stores the plaintext API key in the system keyring
+// and replaces it in the configuration with a reference
+func migrateAPIKeyToKeyring(ctx context.Context, cfg *config.Config) error {
+	if err := config.KeyringSet(ctx, apiKeyKeyringAccount, cfg.LLM.APIKey); err != nil {
+		return err
+	}
+
+	for name, value := range map[string]string{
+		"llm.api_key_keyring": apiKeyKeyringAccount,
+		"llm.api_key_cmd":     "",
+		"llm.api_key_file":    "",
+		"llm.api_key":         "",
+	} {
+		key, err := config.LookupKey(name)
+		if err != nil {
+			return err
+		}
+		if err := key.Set(cfg, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configPrompts lists the settings asked for by the interactive config command
+var configPrompts = []struct {
+	key   string
+	label string
+}{
+	{"default.interval", "Default commit interval"},
+	{"llm.provider", "LLM provider (openai, anthropic, ollama)"},
+	{"llm.base_url", "API Base URL"},
+	{"llm.api_key", "API key"},
+	{"llm.model", "Model"},
+	{"scheduler.max_concurrent", "Maximum repositories processed at once"},
+	{"scheduler.shutdown_timeout", "Shutdown timeout for running commits"},
+}
+
+// configGetCommand prints a single configuration value
+func configGetCommand(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "Print a configuration value",
+		ArgsUsage: "<key>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "reveal",
+				Usage: "Print secret values such as the API key unmasked",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("key argument required")
 			}
 
-			fmt.Printf("Model (current: %s): ", cfg.LLM.Model)
-			scanner.Scan()
-			input = strings.TrimSpace(scanner.Text())
-			if input != "" {
-				cfg.LLM.Model = input
+			key, err := config.LookupKey(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+
+			value := key.Get(cfg)
+			if key.Secret && !c.Bool("reveal") {
+				value = maskAPIKey(value)
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+// configSetCommand changes a single configuration value
+func configSetCommand(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "set",
+		Usage:     "Change a configuration value",
+		ArgsUsage: "<key> <value>",
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return fmt.Errorf("key and value arguments required")
+			}
+
+			key, err := config.LookupKey(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+
+			if err := key.Set(cfg, c.Args().Get(1)); err != nil {
+				return err
 			}
 
-			// Save configuration
 			if err := cfg.Save(); err != nil {
 				return fmt.Errorf("failed to save configuration: %w", err)
 			}
+			return nil
+		},
+	}
+}
 
-			fmt.Println("Configuration saved successfully")
+// configUnsetCommand restores a configuration value to its default
+func configUnsetCommand(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "unset",
+		Usage:     "Restore a configuration value to its default",
+		ArgsUsage: "<key>",
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return fmt.Errorf("key argument required")
+			}
+
+			key, err := config.LookupKey(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+
+			key.Unset(cfg)
+
+			if err := cfg.Save(); err != nil {
+				return fmt.Errorf("failed to save configuration: %w", err)
+			}
 			return nil
 		},
 	}
 }
 
+// configListCommand prints every configuration value
+func configListCommand(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "Print all configuration values",
+		Action: func(c *cli.Context) error {
+			for i := range config.Keys {
+				key := &config.Keys[i]
+				value := key.Get(cfg)
+				if key.Secret {
+					value = maskAPIKey(value)
+				}
+				fmt.Printf("%s=%s\n", key.Name, value)
+			}
+			for _, endpoint := range cfg.LLM.Endpoints {
+				for _, setting := range endpoint.Settings() {
+					fmt.Printf("%s=%s\n", setting[0], setting[1])
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// configShowCommand prints the configuration, optionally explaining where each value came from
+func configShowCommand(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "show",
+		Usage: "Print the configuration file contents, or with --effective the values in use and their sources",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "effective",
+				Usage: "Show the values in use after environment overrides and where each came from",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			configPath, configSource, err := config.ResolveConfigFilePath(c.String("config"))
+			if err != nil {
+				return err
+			}
+
+			if !c.Bool("effective") {
+				fmt.Printf("# %s\n", configPath)
+				for i := range config.Keys {
+					key := &config.Keys[i]
+					value := key.Get(cfg.Persisted())
+					if key.Secret {
+						value = maskAPIKey(value)
+					}
+					fmt.Printf("%s=%s\n", key.Name, value)
+				}
+				for _, endpoint := range cfg.LLM.Endpoints {
+					for _, setting := range endpoint.Settings() {
+						fmt.Printf("%s=%s\n", setting[0], setting[1])
+					}
+				}
+				return nil
+			}
+
+			dbPath, dbSource, err := config.ResolveDatabasePath(c.String("db"))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			fmt.Fprintf(w, "config file\t%s\t%s\n", configPath, configSource)
+			fmt.Fprintf(w, "database\t%s\t%s\n", dbPath, dbSource)
+			for i := range config.Keys {
+				key := &config.Keys[i]
+				value := key.Get(cfg)
+				if key.Secret {
+					value = maskAPIKey(value)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, value, cfg.Source(key.Name))
+			}
+			for _, endpoint := range cfg.LLM.Endpoints {
+				for _, setting := range endpoint.Settings() {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", setting[0], setting[1], cfg.Source(setting[0]))
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
 // maskAPIKey masks most of the API key for display
 func maskAPIKey(key string) string {
 	if key == "" {
@@ -272,6 +786,11 @@ func RunCommand(database *db.DB, cfg *config.Config) *cli.Command {
 		Name:  "run",
 		Usage: "Start the commit scheduler",
 		Action: func(c *cli.Context) error {
+			shutdownTimeout, err := time.ParseDuration(cfg.Scheduler.ShutdownTimeout)
+			if err != nil {
+				return fmt.Errorf("invalid shutdown timeout: %w", err)
+			}
+
 			runner := scheduler.NewTaskRunner(database, cfg)
 			if err := runner.Start(); err != nil {
 				return fmt.Errorf("failed to start scheduler: %w", err)
@@ -285,10 +804,451 @@ func RunCommand(database *db.DB, cfg *config.Config) *cli.Command {
 
 			// Wait for interrupt signal
 			<-sigCh
-			fmt.Println("\nShutting down...")
+			fmt.Printf("\nShutting down, waiting up to %s for running commits (press Ctrl+C again to force)...\n", shutdownTimeout)
+
+			// A second signal aborts without waiting
+			go func() {
+				<-sigCh
+				fmt.Println("Forced shutdown")
+				os.Exit(1)
+			}()
+
+			interrupted := runner.Stop(shutdownTimeout)
+			if len(interrupted) > 0 {
+				fmt.Println("Interrupted runs:")
+				for _, path := range interrupted {
+					fmt.Printf("  %s\n", path)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// HistoryCommand shows recorded task runs
+func HistoryCommand(database *db.DB) *cli.Command {
+	return &cli.Command{
+		Name:      "history",
+		Usage:     "Show the outcome of previous scheduler runs",
+		ArgsUsage: "[path or id]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Only show runs since a duration ago (e.g. 24h) or a date (2006-01-02 or RFC 3339)",
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "Maximum number of runs to show",
+				Value: 20,
+			},
+			&cli.StringFlag{
+				Name:  "status",
+				Usage: "Only show runs with this outcome (committed, pushed, skipped-no-changes, skipped-overlap, queued-overlap, skipped-branch, blocked-secrets, deferred, sync-diverged, sync-conflict, interrupted, failed)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print runs as JSON",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() > 1 {
+				return fmt.Errorf("at most one path or id argument allowed")
+			}
+
+			filter := db.RunFilter{
+				Outcome: c.String("status"),
+				Limit:   c.Int("limit"),
+			}
+
+			if c.NArg() == 1 {
+				arg := c.Args().Get(0)
+				var id int64
+				if _, err := fmt.Sscanf(arg, "%d", &id); err == nil {
+					filter.TaskID = id
+				} else {
+					absPath, err := filepath.Abs(arg)
+					if err != nil {
+						return fmt.Errorf("failed to get absolute path: %w", err)
+					}
+					filter.Path = absPath
+				}
+			}
+
+			if since := c.String("since"); since != "" {
+				sinceTime, err := parseSince(since)
+				if err != nil {
+					return err
+				}
+				filter.Since = sinceTime
+			}
+
+			runs, err := database.GetRuns(filter)
+			if err != nil {
+				return fmt.Errorf("failed to read history: %w", err)
+			}
+
+			if c.Bool("json") {
+				if runs == nil {
+					runs = []db.Run{}
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(runs)
+			}
+
+			if len(runs) == 0 {
+				fmt.Println("No runs recorded")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STARTED\tTASK\tPATH\tOUTCOME\tCOMMIT\tSOURCE\tDETAILS")
+			for _, run := range runs {
+				details := firstLine(run.Message)
+				if run.Error != "" {
+					details = firstLine(run.Error)
+				} else if run.Secrets != "" {
+					details += fmt.Sprintf(" (%d secrets left unstaged)", strings.Count(run.Secrets, "\n")+1)
+				}
+				if run.Sync != "" && run.Error == "" {
+					details += " (" + run.Sync + ")"
+				}
+				source := run.MessageSource
+				if run.Endpoint != "" {
+					source += " (" + run.Endpoint + ")"
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+					run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+					run.TaskID,
+					run.Path,
+					run.Outcome,
+					shortHash(run.CommitHash),
+					source,
+					details,
+				)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// PromptCommand inspects the prompt used to generate commit messages
+func PromptCommand(database *db.DB, cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "prompt",
+		Usage: "Inspect the prompt used to generate commit messages",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "render",
+				Usage:     "Print the prompt for a repository's pending changes without calling the LLM",
+				ArgsUsage: "<path>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "template",
+						Usage: "Render this template file instead of the task's or the configured one",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("path argument required")
+					}
+
+					absPath, err := filepath.Abs(c.Args().Get(0))
+					if err != nil {
+						return fmt.Errorf("failed to get absolute path: %w", err)
+					}
+
+					stored, err := database.GetTask(absPath)
+					if err != nil {
+						return err
+					}
+
+					task, repoFile, err := scheduler.EffectiveTask(*stored)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Ignoring repository config: %v\n", err)
+					}
+
+					repoManager, err := git.NewRepoManager(task.Path)
+					if err != nil {
+						return err
+					}
+					branch, err := repoManager.CurrentBranch()
+					if err != nil {
+						return err
+					}
+
+					// Preview the changes a run would commit without staging them
+					diff, err := repoManager.PreviewDiff(c.Context, task.AutoAdd, task.ExcludePatterns)
+					if err != nil {
+						return fmt.Errorf("failed to get diff: %w", err)
+					}
+					if diff == "" {
+						fmt.Fprintln(os.Stderr, "No pending changes; rendering with an empty diff")
+					}
+
+					scanner, err := secrets.NewScanner(cfg.SecretPatterns)
+					if err != nil {
+						return err
+					}
+					diff, findings := scanner.RedactDiff(diff)
+					if len(findings) > 0 {
+						fmt.Fprintf(os.Stderr, "Redacted %d possible secrets (%s)\n", len(findings), strings.Join(secrets.Rules(findings), ", "))
+						if task.SkipLLMOnSecrets {
+							fmt.Fprintln(os.Stderr, "The task is registered with --no-llm-on-secrets, so at run time this diff is not sent to the LLM")
+						}
+					}
+
+					opts := scheduler.LLMOptions(task, repoFile, repoManager, branch)
+					if c.IsSet("template") {
+						opts.TemplateFile = c.String("template")
+					}
+
+					client := llm.NewClient(cfg.LLM)
+					prompt, overBudget, err := client.RenderPrompt(c.Context, diff, opts)
+					if err != nil {
+						return err
+					}
+					if overBudget {
+						fmt.Fprintln(os.Stderr, "The diff exceeds the token budget; at run time it is summarized per file and the summaries replace it")
+					}
+
+					fmt.Println(strings.TrimRight(prompt, "\n"))
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// SquashCommand collapses the latest automated commits on the current branch into one
+func SquashCommand(database *db.DB, cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "squash",
+		Usage:     "Squash the latest commitmonk commits on the current branch into one commit",
+		ArgsUsage: "[path]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "message",
+				Aliases: []string{"m"},
+				Usage:   "Message for the squashed commit instead of one generated by the LLM",
+			},
+			&cli.BoolFlag{
+				Name:  "force-with-lease",
+				Usage: "Squash commits that are already pushed and force-push the branch to its upstream if no one else has pushed since",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show the commits and the message without squashing",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() > 1 {
+				return fmt.Errorf("at most one path argument expected")
+			}
+			absPath, err := filepath.Abs(c.Args().First())
+			if err != nil {
+				return fmt.Errorf("failed to get absolute path: %w", err)
+			}
+
+			repoManager, err := git.NewRepoManager(absPath)
+			if err != nil {
+				return err
+			}
+			branch, err := repoManager.CurrentBranch()
+			if err != nil {
+				return err
+			}
+			if branch == "" {
+				return fmt.Errorf("HEAD is detached; check out a branch to squash")
+			}
+
+			// A registered repository supplies the author and prompt settings
+			task := db.Task{Path: absPath}
+			var repoFile *repoconfig.File
+			if stored, err := database.GetTask(absPath); err == nil {
+				task, repoFile, err = scheduler.EffectiveTask(*stored)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Ignoring repository config: %v\n", err)
+				}
+			}
+
+			// Commits are commitmonk's if a run recorded them or they name it
+			runs, err := database.GetRuns(db.RunFilter{Path: absPath})
+			if err != nil {
+				return err
+			}
+			recorded := make(map[string]bool)
+			for _, run := range runs {
+				if run.CommitHash != "" {
+					recorded[run.CommitHash] = true
+				}
+			}
+			commits, base, err := repoManager.TrailingCommits(func(commit git.CommitInfo) bool {
+				return recorded[commit.Hash] || commit.ByBot()
+			})
+			if err != nil {
+				return err
+			}
+			if len(commits) < 2 {
+				fmt.Printf("Nothing to squash: found %d commitmonk commits at the tip of %s\n", len(commits), branch)
+				return nil
+			}
+
+			fmt.Printf("Squashing %d commits on %s:\n", len(commits), branch)
+			subjects := make([]string, len(commits))
+			for i, commit := range commits {
+				fmt.Printf("  %s %s\n", shortHash(commit.Hash), commit.Subject())
+				// Oldest first for the prompt
+				subjects[len(commits)-1-i] = commit.Subject()
+			}
 
-			runner.Stop()
+			// Rewriting pushed commits needs the lease, and an upstream to push to
+			pushed, err := repoManager.RemoteBranchesContaining(c.Context, commits[len(commits)-1].Hash)
+			if err != nil {
+				return err
+			}
+			upstream, expected, err := repoManager.Upstream(c.Context)
+			if err != nil {
+				return err
+			}
+			pushUpstream := false
+			for _, remoteBranch := range pushed {
+				if remoteBranch == upstream {
+					pushUpstream = true
+				} else if c.Bool("force-with-lease") {
+					fmt.Fprintf(os.Stderr, "Warning: %s also contains these commits and is not rewritten\n", remoteBranch)
+				}
+			}
+			if len(pushed) > 0 && !c.Bool("force-with-lease") {
+				return fmt.Errorf("these commits are already pushed to %s; rerun with --force-with-lease to squash them and rewrite the upstream branch", strings.Join(pushed, ", "))
+			}
+
+			message := c.String("message")
+			if message == "" {
+				message, err = squashMessage(c, cfg, task, repoFile, repoManager, branch, base, commits[0].Hash, subjects)
+				if err != nil {
+					return err
+				}
+			}
+			if c.Bool("dry-run") {
+				fmt.Printf("Message:\n%s\n", message)
+				return nil
+			}
+
+			// The squashed commit is curated, so commitmonk is not credited
+			var opts git.CommitOptions
+			if task.Author != "" {
+				if opts.Author, err = git.ParseIdentity(task.Author); err != nil {
+					return err
+				}
+			}
+			hash, err := repoManager.Squash(c.Context, commits, base, message, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Squashed into %s %s (previous tip %s)\n", shortHash(hash), firstLine(message), shortHash(commits[0].Hash))
+
+			if pushUpstream {
+				if err := repoManager.ForcePushWithLease(c.Context, expected); err != nil {
+					return fmt.Errorf("squashed locally but %w", err)
+				}
+				fmt.Printf("Pushed %s to %s\n", branch, upstream)
+			}
 			return nil
 		},
 	}
 }
+
+// squashMessage asks the LLM for a message summarizing the combined diff of
+// squashed commits and their subjects
+func squashMessage(c *cli.Context, cfg *config.Config, task db.Task, repoFile *repoconfig.File, repoManager *git.RepoManager, branch, base, tip string, subjects []string) (string, error) {
+	client := llm.NewClient(cfg.LLM)
+	if !client.HasCredentials() {
+		return "", fmt.Errorf("no LLM configured; use --message to provide the squashed commit's message")
+	}
+
+	diff, err := repoManager.DiffBetween(c.Context, base, tip)
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	scanner, err := secrets.NewScanner(cfg.SecretPatterns)
+	if err != nil {
+		return "", err
+	}
+	diff, findings := scanner.RedactDiff(diff)
+	if len(findings) > 0 {
+		fmt.Fprintf(os.Stderr, "Redacted %d possible secrets (%s)\n", len(findings), strings.Join(secrets.Rules(findings), ", "))
+		if task.SkipLLMOnSecrets {
+			return "", fmt.Errorf("the diff contains secrets and the repository is registered with --no-llm-on-secrets; use --message to provide the squashed commit's message")
+		}
+	}
+
+	opts := scheduler.LLMOptions(task, repoFile, repoManager, branch)
+	opts.Squashed = subjects
+	message, _, err := client.GenerateCommitMessage(c.Context, diff, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate commit message: %w", err)
+	}
+	return message, nil
+}
+
+// parseSince accepts either a duration relative to now or an absolute date
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q: expected a duration like 24h or a date like 2006-01-02", value)
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// firstLine returns the first line of a possibly multi-line string
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// describePushTarget formats a task's push remote and branch, leaving out
+// what git config decides
+func describePushTarget(task db.Task) string {
+	switch {
+	case task.PushBranch == "":
+		return "remote " + task.PushRemote
+	case task.PushRemote == "":
+		return "branch " + task.PushBranch
+	}
+	return task.PushRemote + "/" + task.PushBranch
+}
+
+// estimateNextRun guesses when an interval task fires next. The scheduler
+// counts intervals from its own start, which only the running process knows,
+// so the estimate is one interval after the task's last run, or from now if
+// that has already passed.
+func estimateNextRun(database *db.DB, task db.Task) (time.Time, bool) {
+	now := time.Now()
+	runs, err := database.GetRuns(db.RunFilter{TaskID: task.ID, Limit: 1})
+	if err == nil && len(runs) == 1 {
+		if nextRun, err := scheduler.NextRun(task, runs[0].StartedAt); err == nil && nextRun.After(now) {
+			return nextRun, true
+		}
+	}
+	nextRun, err := scheduler.NextRun(task, now)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return nextRun, true
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -13,13 +17,65 @@ import (
 type Config struct {
 	DefaultInterval string
 	LLM             LLMConfig
+	Scheduler       SchedulerConfig
+	// SecretPatterns are extra regular expressions, by name, for secrets to
+	// redact from diffs
+	SecretPatterns map[string]string
+
+	// path is the file the configuration was loaded from and is saved to
+	path string
+	// sources records where each key's value came from, by key name
+	sources map[string]string
+	// fileValues holds the file's values for keys overridden by the environment
+	// so that Save never persists an override
+	fileValues map[string]string
 }
 
 // LLMConfig holds LLM API configuration
 type LLMConfig struct {
-	BaseURL string
-	APIKey  string
-	Model   string
+	// Provider selects the API flavour: openai (default), anthropic or ollama
+	Provider string
+	BaseURL  string
+	APIKey   string
+	Model    string
+	// APIKeyCmd, APIKeyFile and APIKeyKeyring reference an API key kept
+	// outside the config file; they are used when APIKey is empty
+	APIKeyCmd     string
+	APIKeyFile    string
+	APIKeyKeyring string
+	// TokenBudget caps the estimated size of a prompt in tokens; larger
+	// diffs are summarized per file before the message is generated
+	TokenBudget int
+	// TokenBudgets overrides TokenBudget for specific models
+	TokenBudgets map[string]int
+	// PromptTemplate is a text/template file rendered into the prompt
+	PromptTemplate string
+	// MessageFormat is oneline for a subject only, or full for a subject and body
+	MessageFormat string
+	// Style is the commit convention generated messages are validated
+	// against: conventional or none
+	Style string
+	// CommitTypes is a comma-separated list of allowed Conventional Commits types
+	CommitTypes string
+	// RepairAttempts is how often an invalid message is sent back to the model
+	RepairAttempts int
+	// Timeout bounds each HTTP request to the provider
+	Timeout string
+	// MaxRetries is how often rate-limited, failed or timed out requests are retried
+	MaxRetries int
+	// Endpoints are tried in order when the primary endpoint fails
+	Endpoints []Endpoint
+}
+
+// SchedulerConfig holds settings for the commit scheduler
+type SchedulerConfig struct {
+	// MaxConcurrent limits how many repositories are processed at once
+	MaxConcurrent int
+	// ShutdownTimeout is how long to wait for in-flight tasks when stopping
+	ShutdownTimeout string
+	// OnLLMError is fallback to commit with a static or derived message when
+	// the LLM fails temporarily, or defer to try again on the next run
+	OnLLMError string
 }
 
 // DefaultConfig returns the default configuration
@@ -27,20 +83,43 @@ func DefaultConfig() *Config {
 	return &Config{
 		DefaultInterval: "5m",
 		LLM: LLMConfig{
-			BaseURL: "https://api.openai.com/v1",
-			Model:   "gpt-4",
+			Provider: "openai",
+			BaseURL:  "https://api.openai.com/v1",
+			Model:    "gpt-4",
+			// Leaves room for the reply in an 8k context window
+			TokenBudget:    6000,
+			MessageFormat:  "oneline",
+			Style:          "conventional",
+			CommitTypes:    "feat,fix,docs,style,refactor,perf,test,build,ci,chore,revert",
+			RepairAttempts: 2,
+			Timeout:        "60s",
+			MaxRetries:     3,
+		},
+		Scheduler: SchedulerConfig{
+			MaxConcurrent:   4,
+			ShutdownTimeout: "30s",
+			OnLLMError:      "fallback",
 		},
 	}
 }
 
-// GetConfigDir returns the platform-specific config directory
+// GetConfigDir returns the config directory. COMMITMONK_CONFIG_DIR takes
+// precedence, then XDG_CONFIG_HOME, then the platform default.
 func GetConfigDir() (string, error) {
+	if dir := os.Getenv(EnvConfigDir); dir != "" {
+		return dir, nil
+	}
+
 	var configDir string
 
 	switch runtime.GOOS {
 	case "windows":
 		configDir = filepath.Join(os.Getenv("APPDATA"), "commitmonk")
 	case "darwin", "linux":
+		if xdgDir := os.Getenv("XDG_CONFIG_HOME"); xdgDir != "" {
+			configDir = filepath.Join(xdgDir, "commitmonk")
+			break
+		}
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			return "", fmt.Errorf("failed to get home directory: %w", err)
@@ -55,25 +134,74 @@ func GetConfigDir() (string, error) {
 
 // GetConfigFilePath returns the full path to the config file
 func GetConfigFilePath() (string, error) {
+	path, _, err := ResolveConfigFilePath("")
+	return path, err
+}
+
+// ResolveConfigFilePath returns the config file to use and where that choice
+// came from: the --config flag, COMMITMONK_CONFIG, or the config directory
+func ResolveConfigFilePath(flagValue string) (string, string, error) {
+	return resolvePath(flagValue, EnvConfigFile, "config.ini")
+}
+
+// ResolveDatabasePath returns the database file to use and where that choice
+// came from: the --db flag, COMMITMONK_DB, or the config directory
+func ResolveDatabasePath(flagValue string) (string, string, error) {
+	return resolvePath(flagValue, EnvDatabase, "commitmonk.db")
+}
+
+// resolvePath applies flag > env > config directory precedence to a file path.
+// A config directory from COMMITMONK_CONFIG_DIR is reported as that variable.
+func resolvePath(flagValue, envVar, fileName string) (string, string, error) {
+	if flagValue != "" {
+		return flagValue, SourceFlag, nil
+	}
+	if envValue := os.Getenv(envVar); envValue != "" {
+		return envValue, envSource(envVar), nil
+	}
+
 	configDir, err := GetConfigDir()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	return filepath.Join(configDir, "config.ini"), nil
+	source := SourceDefault
+	if os.Getenv(EnvConfigDir) != "" {
+		source = envSource(EnvConfigDir)
+	}
+	return filepath.Join(configDir, fileName), source, nil
 }
 
-// LoadConfig loads the application configuration from the config file
-func LoadConfig() (*Config, error) {
-	configPath, err := GetConfigFilePath()
+// LoadConfig loads the application configuration from the given config file,
+// creating it with defaults if it does not exist, and then applies
+// COMMITMONK_* environment overrides. An empty path uses GetConfigFilePath.
+func LoadConfig(configPath string) (*Config, error) {
+	if configPath == "" {
+		var err error
+		configPath, err = GetConfigFilePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := loadConfigFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadConfigFile reads the config file, creating it if it does not exist
+func loadConfigFile(configPath string) (*Config, error) {
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Create default config
 		config := DefaultConfig()
+		config.path = configPath
 		if err := config.Save(); err != nil {
 			return nil, fmt.Errorf("failed to create default config: %w", err)
 		}
@@ -87,6 +215,13 @@ func LoadConfig() (*Config, error) {
 	}
 
 	config := DefaultConfig()
+	config.path = configPath
+	config.sources = make(map[string]string)
+	for _, key := range Keys {
+		if iniFile.Section(key.section()).HasKey(key.name()) {
+			config.sources[key.Name] = SourceFile
+		}
+	}
 
 	// Load default section
 	defaultSection := iniFile.Section("default")
@@ -97,21 +232,102 @@ func LoadConfig() (*Config, error) {
 	// Load LLM section
 	llmSection := iniFile.Section("llm")
 	if llmSection != nil {
+		config.LLM.Provider = llmSection.Key("provider").MustString(config.LLM.Provider)
 		config.LLM.BaseURL = llmSection.Key("base_url").MustString(config.LLM.BaseURL)
 		config.LLM.APIKey = llmSection.Key("api_key").String()
 		config.LLM.Model = llmSection.Key("model").MustString(config.LLM.Model)
+		config.LLM.APIKeyCmd = llmSection.Key("api_key_cmd").String()
+		config.LLM.APIKeyFile = llmSection.Key("api_key_file").String()
+		config.LLM.APIKeyKeyring = llmSection.Key("api_key_keyring").String()
+		config.LLM.TokenBudget = llmSection.Key("token_budget").MustInt(config.LLM.TokenBudget)
+		config.LLM.PromptTemplate = llmSection.Key("prompt_template").String()
+		config.LLM.MessageFormat = llmSection.Key("message_format").MustString(config.LLM.MessageFormat)
+		config.LLM.Style = llmSection.Key("style").MustString(config.LLM.Style)
+		config.LLM.CommitTypes = llmSection.Key("commit_types").MustString(config.LLM.CommitTypes)
+		config.LLM.RepairAttempts = llmSection.Key("repair_attempts").MustInt(config.LLM.RepairAttempts)
+		config.LLM.Timeout = llmSection.Key("timeout").MustString(config.LLM.Timeout)
+		config.LLM.MaxRetries = llmSection.Key("max_retries").MustInt(config.LLM.MaxRetries)
+	}
+
+	// Load per-model token budgets
+	if iniFile.HasSection("token_budgets") {
+		config.LLM.TokenBudgets = make(map[string]int)
+		for _, key := range iniFile.Section("token_budgets").Keys() {
+			budget, err := key.Int()
+			if err != nil || budget < MinTokenBudget {
+				return nil, fmt.Errorf("invalid token budget for model %s: must be a number of at least %d", key.Name(), MinTokenBudget)
+			}
+			config.LLM.TokenBudgets[key.Name()] = budget
+		}
+	}
+
+	// Load custom secret patterns
+	if iniFile.HasSection("secret_patterns") {
+		config.SecretPatterns = make(map[string]string)
+		for _, key := range iniFile.Section("secret_patterns").Keys() {
+			if _, err := regexp.Compile(key.String()); err != nil {
+				return nil, fmt.Errorf("invalid secret pattern %s: %w", key.Name(), err)
+			}
+			config.SecretPatterns[key.Name()] = key.String()
+		}
+	}
+
+	// Load fallback endpoints
+	for name, source := range endpointSources(iniFile) {
+		config.sources[name] = source
+	}
+	config.LLM.Endpoints, err = loadEndpoints(iniFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load scheduler section
+	schedulerSection := iniFile.Section("scheduler")
+	if schedulerSection != nil {
+		config.Scheduler.MaxConcurrent = schedulerSection.Key("max_concurrent").MustInt(config.Scheduler.MaxConcurrent)
+		config.Scheduler.ShutdownTimeout = schedulerSection.Key("shutdown_timeout").MustString(config.Scheduler.ShutdownTimeout)
+		config.Scheduler.OnLLMError = schedulerSection.Key("on_llm_error").MustString(config.Scheduler.OnLLMError)
 	}
 
 	return config, nil
 }
 
-// Save writes the configuration to the config file
+// TokenBudgetFor returns the prompt token budget for a model
+func (l LLMConfig) TokenBudgetFor(model string) int {
+	if budget, ok := l.TokenBudgets[model]; ok {
+		return budget
+	}
+	return l.TokenBudget
+}
+
+// RequestTimeout returns the timeout for a single LLM request
+func (l LLMConfig) RequestTimeout() time.Duration {
+	// Values are validated when set; fall back to the default for hand-edited files
+	timeout, err := time.ParseDuration(l.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout, _ = time.ParseDuration(DefaultConfig().LLM.Timeout)
+	}
+	return timeout
+}
+
+// FilePath returns the file Save writes to
+func (c *Config) FilePath() (string, error) {
+	if c.path != "" {
+		return c.path, nil
+	}
+	return GetConfigFilePath()
+}
+
+// Save writes the configuration to the config file. Values overridden by
+// environment variables are written with the value they have in the file.
 func (c *Config) Save() error {
-	configPath, err := GetConfigFilePath()
+	configPath, err := c.FilePath()
 	if err != nil {
 		return err
 	}
 
+	c = c.Persisted()
+
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
@@ -134,6 +350,10 @@ func (c *Config) Save() error {
 	if err != nil {
 		return fmt.Errorf("failed to create llm section: %w", err)
 	}
+	_, err = llmSection.NewKey("provider", c.LLM.Provider)
+	if err != nil {
+		return fmt.Errorf("failed to write provider key: %w", err)
+	}
 	_, err = llmSection.NewKey("base_url", c.LLM.BaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to write base_url key: %w", err)
@@ -146,6 +366,116 @@ func (c *Config) Save() error {
 	if err != nil {
 		return fmt.Errorf("failed to write model key: %w", err)
 	}
+	_, err = llmSection.NewKey("token_budget", strconv.Itoa(c.LLM.TokenBudget))
+	if err != nil {
+		return fmt.Errorf("failed to write token_budget key: %w", err)
+	}
+	_, err = llmSection.NewKey("message_format", c.LLM.MessageFormat)
+	if err != nil {
+		return fmt.Errorf("failed to write message_format key: %w", err)
+	}
+	_, err = llmSection.NewKey("style", c.LLM.Style)
+	if err != nil {
+		return fmt.Errorf("failed to write style key: %w", err)
+	}
+	_, err = llmSection.NewKey("commit_types", c.LLM.CommitTypes)
+	if err != nil {
+		return fmt.Errorf("failed to write commit_types key: %w", err)
+	}
+	_, err = llmSection.NewKey("repair_attempts", strconv.Itoa(c.LLM.RepairAttempts))
+	if err != nil {
+		return fmt.Errorf("failed to write repair_attempts key: %w", err)
+	}
+	_, err = llmSection.NewKey("timeout", c.LLM.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to write timeout key: %w", err)
+	}
+	_, err = llmSection.NewKey("max_retries", strconv.Itoa(c.LLM.MaxRetries))
+	if err != nil {
+		return fmt.Errorf("failed to write max_retries key: %w", err)
+	}
+	if c.LLM.PromptTemplate != "" {
+		if _, err := llmSection.NewKey("prompt_template", c.LLM.PromptTemplate); err != nil {
+			return fmt.Errorf("failed to write prompt_template key: %w", err)
+		}
+	}
+
+	// Only write secret references that are in use
+	secretRefs := []struct {
+		name  string
+		value string
+	}{
+		{"api_key_cmd", c.LLM.APIKeyCmd},
+		{"api_key_file", c.LLM.APIKeyFile},
+		{"api_key_keyring", c.LLM.APIKeyKeyring},
+	}
+	for _, ref := range secretRefs {
+		if ref.value == "" {
+			continue
+		}
+		if _, err := llmSection.NewKey(ref.name, ref.value); err != nil {
+			return fmt.Errorf("failed to write %s key: %w", ref.name, err)
+		}
+	}
+
+	// Save per-model token budgets
+	if len(c.LLM.TokenBudgets) > 0 {
+		budgetSection, err := iniFile.NewSection("token_budgets")
+		if err != nil {
+			return fmt.Errorf("failed to create token_budgets section: %w", err)
+		}
+		models := make([]string, 0, len(c.LLM.TokenBudgets))
+		for model := range c.LLM.TokenBudgets {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		for _, model := range models {
+			if _, err := budgetSection.NewKey(model, strconv.Itoa(c.LLM.TokenBudgets[model])); err != nil {
+				return fmt.Errorf("failed to write token budget for %s: %w", model, err)
+			}
+		}
+	}
+
+	// Save custom secret patterns
+	if len(c.SecretPatterns) > 0 {
+		patternSection, err := iniFile.NewSection("secret_patterns")
+		if err != nil {
+			return fmt.Errorf("failed to create secret_patterns section: %w", err)
+		}
+		names := make([]string, 0, len(c.SecretPatterns))
+		for name := range c.SecretPatterns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := patternSection.NewKey(name, c.SecretPatterns[name]); err != nil {
+				return fmt.Errorf("failed to write secret pattern %s: %w", name, err)
+			}
+		}
+	}
+
+	// Save fallback endpoints
+	if err := saveEndpoints(iniFile, c.LLM.Endpoints); err != nil {
+		return err
+	}
+
+	// Save scheduler section
+	schedulerSection, err := iniFile.NewSection("scheduler")
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler section: %w", err)
+	}
+	_, err = schedulerSection.NewKey("max_concurrent", strconv.Itoa(c.Scheduler.MaxConcurrent))
+	if err != nil {
+		return fmt.Errorf("failed to write max_concurrent key: %w", err)
+	}
+	_, err = schedulerSection.NewKey("shutdown_timeout", c.Scheduler.ShutdownTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to write shutdown_timeout key: %w", err)
+	}
+	_, err = schedulerSection.NewKey("on_llm_error", c.Scheduler.OnLLMError)
+	if err != nil {
+		return fmt.Errorf("failed to write on_llm_error key: %w", err)
+	}
 
 	// Write to file with restricted permissions
 	if err := iniFile.SaveTo(configPath); err != nil {
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// endpointSectionPrefix starts the name of each fallback endpoint's section
+const endpointSectionPrefix = "endpoint."
+
+// PrimaryEndpoint names the endpoint configured in the [llm] section
+const PrimaryEndpoint = "primary"
+
+// Endpoint is a named LLM endpoint tried when the ones before it fail. It
+// has its own connection settings; prompt and validation settings come from
+// the [llm] section.
+type Endpoint struct {
+	Name     string
+	Provider string
+	BaseURL  string
+	APIKey   string
+	Model    string
+	// APIKeyCmd, APIKeyFile and APIKeyKeyring work as in the [llm] section
+	APIKeyCmd     string
+	APIKeyFile    string
+	APIKeyKeyring string
+	// Timeout overrides the [llm] timeout when set
+	Timeout string
+}
+
+// ForEndpoint returns the LLM configuration with the endpoint's connection
+// settings in place of the primary ones. Credentials are never inherited so
+// the primary API key is not sent to another server.
+func (l LLMConfig) ForEndpoint(e Endpoint) LLMConfig {
+	cfg := l
+	cfg.Provider = e.Provider
+	cfg.BaseURL = e.BaseURL
+	cfg.APIKey = e.APIKey
+	cfg.Model = e.Model
+	cfg.APIKeyCmd = e.APIKeyCmd
+	cfg.APIKeyFile = e.APIKeyFile
+	cfg.APIKeyKeyring = e.APIKeyKeyring
+	if e.Timeout != "" {
+		cfg.Timeout = e.Timeout
+	}
+	cfg.Endpoints = nil
+	return cfg
+}
+
+// AnyConfigured reports whether the primary endpoint or any fallback
+// endpoint can generate commit messages
+func (l LLMConfig) AnyConfigured() bool {
+	if l.IsConfigured() {
+		return true
+	}
+	for _, endpoint := range l.Endpoints {
+		if l.ForEndpoint(endpoint).IsConfigured() {
+			return true
+		}
+	}
+	return false
+}
+
+// Settings returns the endpoint's non-secret settings as "endpoint.NAME.key"
+// names and values for display
+func (e Endpoint) Settings() [][2]string {
+	prefix := endpointSectionPrefix + e.Name + "."
+	return [][2]string{
+		{prefix + "provider", e.Provider},
+		{prefix + "base_url", e.BaseURL},
+		{prefix + "model", e.Model},
+		{prefix + "timeout", e.Timeout},
+	}
+}
+
+// loadEndpoints reads the [endpoint.NAME] sections in the order they appear in the file
+func loadEndpoints(iniFile *ini.File) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, section := range iniFile.Sections() {
+		if !strings.HasPrefix(section.Name(), endpointSectionPrefix) {
+			continue
+		}
+
+		endpoint := Endpoint{
+			Name:          strings.TrimPrefix(section.Name(), endpointSectionPrefix),
+			Provider:      section.Key("provider").MustString(DefaultConfig().LLM.Provider),
+			BaseURL:       section.Key("base_url").String(),
+			APIKey:        section.Key("api_key").String(),
+			Model:         section.Key("model").String(),
+			APIKeyCmd:     section.Key("api_key_cmd").String(),
+			APIKeyFile:    section.Key("api_key_file").String(),
+			APIKeyKeyring: section.Key("api_key_keyring").String(),
+			Timeout:       section.Key("timeout").String(),
+		}
+		if err := endpoint.validate(); err != nil {
+			return nil, fmt.Errorf("invalid [%s] section: %w", section.Name(), err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// endpointSources returns the file as the source of each endpoint key
+// present in the [endpoint.NAME] sections, by key name. It must run before
+// loadEndpoints, which adds the missing keys.
+func endpointSources(iniFile *ini.File) map[string]string {
+	sources := make(map[string]string)
+	for _, section := range iniFile.Sections() {
+		if !strings.HasPrefix(section.Name(), endpointSectionPrefix) {
+			continue
+		}
+		for _, setting := range endpointSettings {
+			if section.HasKey(setting.name) {
+				sources[section.Name()+"."+setting.name] = SourceFile
+			}
+		}
+	}
+	return sources
+}
+
+// validate checks the settings of an endpoint read from the config file
+func (e Endpoint) validate() error {
+	if e.Name == "" || e.Name == PrimaryEndpoint {
+		return fmt.Errorf("endpoint name must not be empty or %q", PrimaryEndpoint)
+	}
+	if err := validateProvider(e.Provider); err != nil {
+		return err
+	}
+	if err := validateModel(e.Model); err != nil {
+		return err
+	}
+	if e.Timeout != "" {
+		return validateEndpointTimeout(e.Timeout)
+	}
+	return nil
+}
+
+// validateModel checks that an endpoint names a model
+func validateModel(value string) error {
+	if value == "" {
+		return fmt.Errorf("model is required")
+	}
+	return nil
+}
+
+// validateEndpointTimeout checks an endpoint's request timeout
+func validateEndpointTimeout(value string) error {
+	if timeout, err := time.ParseDuration(value); err != nil || timeout <= 0 {
+		return fmt.Errorf("timeout must be a positive duration")
+	}
+	return nil
+}
+
+// endpointSetting describes a key of an [endpoint.NAME] section
+type endpointSetting struct {
+	name     string
+	usage    string
+	secret   bool
+	field    func(e *Endpoint) *string
+	validate func(value string) error
+}
+
+// endpointSettings lists the keys of an [endpoint.NAME] section
+var endpointSettings = []endpointSetting{
+	{name: "provider", usage: "LLM API flavour of the endpoint", field: func(e *Endpoint) *string { return &e.Provider }, validate: validateProvider},
+	{name: "base_url", usage: "API base URL of the endpoint", field: func(e *Endpoint) *string { return &e.BaseURL }, validate: validateBaseURL},
+	{name: "api_key", usage: "API key of the endpoint", secret: true, field: func(e *Endpoint) *string { return &e.APIKey }},
+	{name: "model", usage: "Model of the endpoint; setting it creates the endpoint and unsetting it removes the endpoint", field: func(e *Endpoint) *string { return &e.Model }, validate: validateModel},
+	{name: "api_key_cmd", usage: "Command whose output is the endpoint's API key", field: func(e *Endpoint) *string { return &e.APIKeyCmd }},
+	{name: "api_key_file", usage: "File containing the endpoint's API key", field: func(e *Endpoint) *string { return &e.APIKeyFile }},
+	{name: "api_key_keyring", usage: "Account name of the endpoint's API key in the system keyring", field: func(e *Endpoint) *string { return &e.APIKeyKeyring }},
+	{name: "timeout", usage: "Request timeout of the endpoint (default: llm.timeout)", field: func(e *Endpoint) *string { return &e.Timeout }, validate: validateEndpointTimeout},
+}
+
+// endpointKey returns the key for an "endpoint.NAME.setting" name. Setting
+// the model of an endpoint that does not exist yet adds it; its other keys
+// can only be set once it exists, so that every endpoint has a model.
+func endpointKey(keyName string) (*Key, error) {
+	rest := strings.TrimPrefix(keyName, endpointSectionPrefix)
+	dot := strings.LastIndexByte(rest, '.')
+	if dot <= 0 {
+		return nil, fmt.Errorf("unknown config key %q (endpoint keys are %sNAME.SETTING)", keyName, endpointSectionPrefix)
+	}
+	name, settingName := rest[:dot], rest[dot+1:]
+	if name == PrimaryEndpoint {
+		return nil, fmt.Errorf("the %q endpoint is configured with the llm.* keys", PrimaryEndpoint)
+	}
+
+	var setting *endpointSetting
+	names := make([]string, len(endpointSettings))
+	for i := range endpointSettings {
+		names[i] = endpointSettings[i].name
+		if endpointSettings[i].name == settingName {
+			setting = &endpointSettings[i]
+		}
+	}
+	if setting == nil {
+		return nil, fmt.Errorf("unknown config key %q (valid endpoint settings: %s)", keyName, strings.Join(names, ", "))
+	}
+
+	return &Key{
+		Name:   keyName,
+		Usage:  setting.usage,
+		Secret: setting.secret,
+		get: func(c *Config) string {
+			if endpoint := c.LLM.endpoint(name); endpoint != nil {
+				return *setting.field(endpoint)
+			}
+			return ""
+		},
+		set: func(c *Config, value string) error {
+			if setting.validate != nil {
+				if err := setting.validate(value); err != nil {
+					return err
+				}
+			}
+			endpoint := c.LLM.endpoint(name)
+			if endpoint == nil {
+				if setting.name != "model" {
+					return fmt.Errorf("endpoint %q does not exist, set %s%s.model first to add it", name, endpointSectionPrefix, name)
+				}
+				c.LLM.Endpoints = append(c.LLM.Endpoints, Endpoint{Name: name, Provider: DefaultConfig().LLM.Provider})
+				endpoint = &c.LLM.Endpoints[len(c.LLM.Endpoints)-1]
+			}
+			*setting.field(endpoint) = value
+			return nil
+		},
+		unset: func(c *Config) {
+			if setting.name == "model" {
+				c.LLM.removeEndpoint(name)
+				return
+			}
+			if endpoint := c.LLM.endpoint(name); endpoint != nil {
+				value := ""
+				if setting.name == "provider" {
+					value = DefaultConfig().LLM.Provider
+				}
+				*setting.field(endpoint) = value
+			}
+		},
+	}, nil
+}
+
+// endpoint returns the named fallback endpoint, or nil if there is none
+func (l *LLMConfig) endpoint(name string) *Endpoint {
+	for i := range l.Endpoints {
+		if l.Endpoints[i].Name == name {
+			return &l.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// removeEndpoint removes the named fallback endpoint
+func (l *LLMConfig) removeEndpoint(name string) {
+	endpoints := make([]Endpoint, 0, len(l.Endpoints))
+	for _, endpoint := range l.Endpoints {
+		if endpoint.Name != name {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	l.Endpoints = endpoints
+}
+
+// saveEndpoints writes an [endpoint.NAME] section per endpoint, omitting unset keys
+func saveEndpoints(iniFile *ini.File, endpoints []Endpoint) error {
+	for _, endpoint := range endpoints {
+		sectionName := endpointSectionPrefix + endpoint.Name
+		section, err := iniFile.NewSection(sectionName)
+		if err != nil {
+			return fmt.Errorf("failed to create %s section: %w", sectionName, err)
+		}
+
+		keys := []struct {
+			name  string
+			value string
+		}{
+			{"provider", endpoint.Provider},
+			{"base_url", endpoint.BaseURL},
+			{"api_key", endpoint.APIKey},
+			{"model", endpoint.Model},
+			{"api_key_cmd", endpoint.APIKeyCmd},
+			{"api_key_file", endpoint.APIKeyFile},
+			{"api_key_keyring", endpoint.APIKeyKeyring},
+			{"timeout", endpoint.Timeout},
+		}
+		for _, key := range keys {
+			if key.value == "" {
+				continue
+			}
+			if _, err := section.NewKey(key.name, key.value); err != nil {
+				return fmt.Errorf("failed to write %s key in %s section: %w", key.name, sectionName, err)
+			}
+		}
+	}
+	return nil
+}
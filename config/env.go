@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables that locate configuration files
+const (
+	EnvConfigDir  = "COMMITMONK_CONFIG_DIR"
+	EnvConfigFile = "COMMITMONK_CONFIG"
+	EnvDatabase   = "COMMITMONK_DB"
+)
+
+// Sources a setting can come from, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// envPrefix is prepended to a key name to form its environment variable
+const envPrefix = "COMMITMONK_"
+
+// envSource describes a value taken from an environment variable
+func envSource(envVar string) string {
+	return fmt.Sprintf("%s (%s)", SourceEnv, envVar)
+}
+
+// EnvVar returns the environment variable that overrides the key,
+// e.g. COMMITMONK_LLM_API_KEY for llm.api_key
+func (k *Key) EnvVar() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(k.Name, ".", "_"))
+}
+
+// section returns the INI section holding the key
+func (k *Key) section() string {
+	return k.Name[:strings.IndexByte(k.Name, '.')]
+}
+
+// name returns the key's name within its INI section
+func (k *Key) name() string {
+	return k.Name[strings.IndexByte(k.Name, '.')+1:]
+}
+
+// applyEnv overrides file values with COMMITMONK_* environment variables
+func (c *Config) applyEnv() error {
+	for i := range Keys {
+		key := &Keys[i]
+		value, ok := os.LookupEnv(key.EnvVar())
+		if !ok {
+			continue
+		}
+
+		fileValue := key.Get(c)
+		if err := key.set(c, value); err != nil {
+			return fmt.Errorf("invalid value in %s: %w", key.EnvVar(), err)
+		}
+
+		if c.fileValues == nil {
+			c.fileValues = make(map[string]string)
+		}
+		c.fileValues[key.Name] = fileValue
+		c.setSource(key.Name, envSource(key.EnvVar()))
+	}
+	return nil
+}
+
+// Source describes where the named key's current value came from
+func (c *Config) Source(name string) string {
+	if source, ok := c.sources[name]; ok {
+		if source == SourceFile && c.path != "" {
+			return fmt.Sprintf("%s (%s)", SourceFile, c.path)
+		}
+		return source
+	}
+	return SourceDefault
+}
+
+// setSource records where the named key's value came from
+func (c *Config) setSource(name, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[name] = source
+}
+
+// Persisted returns the configuration as it should be written to the config
+// file, with environment overrides replaced by their file values
+func (c *Config) Persisted() *Config {
+	saved := *c
+	for name, value := range c.fileValues {
+		key, err := LookupKey(name)
+		if err != nil {
+			continue
+		}
+		// File values were valid when loaded
+		_ = key.set(&saved, value)
+	}
+	return &saved
+}
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key describes a configuration setting addressable as "section.name"
+type Key struct {
+	Name   string
+	Usage  string
+	Secret bool
+	get    func(c *Config) string
+	set    func(c *Config, value string) error
+	// unset, when set, replaces restoring the default value
+	unset func(c *Config)
+}
+
+// MinTokenBudget is the smallest prompt budget that leaves room for a diff
+const MinTokenBudget = 500
+
+// Providers lists the supported LLM API flavours
+var Providers = []string{"openai", "anthropic", "ollama"}
+
+// MessageFormats lists the supported commit message formats
+var MessageFormats = []string{"oneline", "full"}
+
+// Styles lists the supported commit message conventions
+var Styles = []string{"conventional", "none"}
+
+// Keys lists every setting that can be read or changed with `commitmonk config`
+var Keys = []Key{
+	{
+		Name:  "default.interval",
+		Usage: "Default commit interval for new repositories",
+		get:   func(c *Config) string { return c.DefaultInterval },
+		set: func(c *Config, value string) error {
+			if err := ValidateInterval(value); err != nil {
+				return err
+			}
+			c.DefaultInterval = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.provider",
+		Usage: "LLM API flavour: openai, anthropic or ollama",
+		get:   func(c *Config) string { return c.LLM.Provider },
+		set: func(c *Config, value string) error {
+			if err := validateProvider(value); err != nil {
+				return err
+			}
+			c.LLM.Provider = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.base_url",
+		Usage: "API base URL (left at the OpenAI default, the provider's own endpoint is used)",
+		get:   func(c *Config) string { return c.LLM.BaseURL },
+		set: func(c *Config, value string) error {
+			if err := validateBaseURL(value); err != nil {
+				return err
+			}
+			c.LLM.BaseURL = value
+			return nil
+		},
+	},
+	{
+		Name:   "llm.api_key",
+		Usage:  "API key for the LLM provider",
+		Secret: true,
+		get:    func(c *Config) string { return c.LLM.APIKey },
+		set: func(c *Config, value string) error {
+			c.LLM.APIKey = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.api_key_cmd",
+		Usage: "Command whose output is the API key (e.g. pass show openai)",
+		get:   func(c *Config) string { return c.LLM.APIKeyCmd },
+		set: func(c *Config, value string) error {
+			c.LLM.APIKeyCmd = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.api_key_file",
+		Usage: "File containing the API key",
+		get:   func(c *Config) string { return c.LLM.APIKeyFile },
+		set: func(c *Config, value string) error {
+			c.LLM.APIKeyFile = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.api_key_keyring",
+		Usage: "Account name of the API key in the system keyring",
+		get:   func(c *Config) string { return c.LLM.APIKeyKeyring },
+		set: func(c *Config, value string) error {
+			c.LLM.APIKeyKeyring = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.model",
+		Usage: "Model used to generate commit messages",
+		get:   func(c *Config) string { return c.LLM.Model },
+		set: func(c *Config, value string) error {
+			c.LLM.Model = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.token_budget",
+		Usage: "Estimated prompt size in tokens above which diffs are summarized per file",
+		get:   func(c *Config) string { return strconv.Itoa(c.LLM.TokenBudget) },
+		set: func(c *Config, value string) error {
+			budget, err := strconv.Atoi(value)
+			if err != nil || budget < MinTokenBudget {
+				return fmt.Errorf("token budget must be a number of at least %d", MinTokenBudget)
+			}
+			c.LLM.TokenBudget = budget
+			return nil
+		},
+	},
+	{
+		Name:  "llm.message_format",
+		Usage: "Commit message format: oneline (subject only) or full (subject and body)",
+		get:   func(c *Config) string { return c.LLM.MessageFormat },
+		set: func(c *Config, value string) error {
+			for _, format := range MessageFormats {
+				if value == format {
+					c.LLM.MessageFormat = value
+					return nil
+				}
+			}
+			return fmt.Errorf("unknown message format %q (valid formats: %s)", value, strings.Join(MessageFormats, ", "))
+		},
+	},
+	{
+		Name:  "llm.style",
+		Usage: "Convention generated messages must follow: conventional or none",
+		get:   func(c *Config) string { return c.LLM.Style },
+		set: func(c *Config, value string) error {
+			for _, style := range Styles {
+				if value == style {
+					c.LLM.Style = value
+					return nil
+				}
+			}
+			return fmt.Errorf("unknown style %q (valid styles: %s)", value, strings.Join(Styles, ", "))
+		},
+	},
+	{
+		Name:  "llm.commit_types",
+		Usage: "Comma-separated Conventional Commits types accepted in generated messages",
+		get:   func(c *Config) string { return c.LLM.CommitTypes },
+		set: func(c *Config, value string) error {
+			if strings.Trim(value, ", ") == "" {
+				return fmt.Errorf("at least one commit type is required")
+			}
+			c.LLM.CommitTypes = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.repair_attempts",
+		Usage: "How often an invalid generated message is sent back to the model for repair",
+		get:   func(c *Config) string { return strconv.Itoa(c.LLM.RepairAttempts) },
+		set: func(c *Config, value string) error {
+			attempts, err := strconv.Atoi(value)
+			if err != nil || attempts < 0 {
+				return fmt.Errorf("repair attempts must be a non-negative number")
+			}
+			c.LLM.RepairAttempts = attempts
+			return nil
+		},
+	},
+	{
+		Name:  "llm.timeout",
+		Usage: "Timeout for each request to the LLM provider",
+		get:   func(c *Config) string { return c.LLM.Timeout },
+		set: func(c *Config, value string) error {
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid timeout: %w", err)
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("timeout must be positive")
+			}
+			c.LLM.Timeout = value
+			return nil
+		},
+	},
+	{
+		Name:  "llm.max_retries",
+		Usage: "How often rate-limited, failed or timed out LLM requests are retried",
+		get:   func(c *Config) string { return strconv.Itoa(c.LLM.MaxRetries) },
+		set: func(c *Config, value string) error {
+			retries, err := strconv.Atoi(value)
+			if err != nil || retries < 0 {
+				return fmt.Errorf("retries must be a non-negative number")
+			}
+			c.LLM.MaxRetries = retries
+			return nil
+		},
+	},
+	{
+		Name:  "llm.prompt_template",
+		Usage: "Go text/template file rendered into the prompt",
+		get:   func(c *Config) string { return c.LLM.PromptTemplate },
+		set: func(c *Config, value string) error {
+			if value != "" {
+				path, err := ExpandHome(value)
+				if err != nil {
+					return err
+				}
+				if _, err := os.Stat(path); err != nil {
+					return fmt.Errorf("cannot read prompt template: %w", err)
+				}
+			}
+			c.LLM.PromptTemplate = value
+			return nil
+		},
+	},
+	{
+		Name:  "scheduler.max_concurrent",
+		Usage: "Maximum number of repositories processed at once",
+		get:   func(c *Config) string { return strconv.Itoa(c.Scheduler.MaxConcurrent) },
+		set: func(c *Config, value string) error {
+			maxConcurrent, err := strconv.Atoi(value)
+			if err != nil || maxConcurrent < 1 {
+				return fmt.Errorf("maximum concurrent tasks must be a positive number")
+			}
+			c.Scheduler.MaxConcurrent = maxConcurrent
+			return nil
+		},
+	},
+	{
+		Name:  "scheduler.shutdown_timeout",
+		Usage: "How long to wait for running commits when stopping",
+		get:   func(c *Config) string { return c.Scheduler.ShutdownTimeout },
+		set: func(c *Config, value string) error {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid shutdown timeout: %w", err)
+			}
+			c.Scheduler.ShutdownTimeout = value
+			return nil
+		},
+	},
+	{
+		Name:  "scheduler.on_llm_error",
+		Usage: "When the LLM fails temporarily: fallback (static or derived message) or defer (retry on the next run)",
+		get:   func(c *Config) string { return c.Scheduler.OnLLMError },
+		set: func(c *Config, value string) error {
+			if value != "fallback" && value != "defer" {
+				return fmt.Errorf("must be fallback or defer")
+			}
+			c.Scheduler.OnLLMError = value
+			return nil
+		},
+	},
+}
+
+// LookupKey finds a configuration key by name, including the
+// "endpoint.NAME.setting" keys of fallback endpoints
+func LookupKey(name string) (*Key, error) {
+	for i := range Keys {
+		if Keys[i].Name == name {
+			return &Keys[i], nil
+		}
+	}
+	if strings.HasPrefix(name, endpointSectionPrefix) {
+		return endpointKey(name)
+	}
+
+	names := make([]string, 0, len(Keys)+1)
+	for _, key := range Keys {
+		names = append(names, key.Name)
+	}
+	names = append(names, endpointSectionPrefix+"NAME.SETTING")
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown config key %q (valid keys: %s)", name, strings.Join(names, ", "))
+}
+
+// Get returns the key's current value in c
+func (k *Key) Get(c *Config) string {
+	return k.get(c)
+}
+
+// Set validates value and stores it in c so that Save persists it
+func (k *Key) Set(c *Config, value string) error {
+	if err := k.set(c, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", k.Name, err)
+	}
+	delete(c.fileValues, k.Name)
+	c.setSource(k.Name, SourceFile)
+	return nil
+}
+
+// Unset restores the key's default value in c so that Save persists it
+func (k *Key) Unset(c *Config) {
+	if k.unset != nil {
+		k.unset(c)
+	} else {
+		// Defaults are always valid, so the error can be ignored
+		_ = k.set(c, k.get(DefaultConfig()))
+	}
+	delete(c.fileValues, k.Name)
+	c.setSource(k.Name, SourceDefault)
+}
+
+// validateProvider checks that an LLM provider is supported
+func validateProvider(value string) error {
+	for _, provider := range Providers {
+		if value == provider {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown provider %q (valid providers: %s)", value, strings.Join(Providers, ", "))
+}
+
+// validateBaseURL checks that an API base URL is an HTTP URL
+func validateBaseURL(value string) error {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("base URL must start with http:// or https://")
+	}
+	return nil
+}
+
+// ValidateInterval checks that a commit interval is a duration of at least one minute
+func ValidateInterval(interval string) error {
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval format: %w", err)
+	}
+	if duration < time.Minute {
+		return fmt.Errorf("interval must be at least 1 minute")
+	}
+	return nil
+}
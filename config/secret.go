@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// keyringService is the service name API keys are stored under in the system keyring
+const keyringService = "commitmonk"
+
+// secretCommandTimeout bounds api_key_cmd and the keyring tools, which can
+// wait indefinitely on a pinentry prompt or a locked keyring
+const secretCommandTimeout = 30 * time.Second
+
+// HasAPIKey reports whether an API key or a reference to one is configured
+func (l LLMConfig) HasAPIKey() bool {
+	return l.APIKey != "" || l.APIKeyCmd != "" || l.APIKeyFile != "" || l.APIKeyKeyring != ""
+}
+
+// RequiresAPIKey reports whether the configured provider needs an API key.
+// Local servers such as Ollama accept requests without one.
+func (l LLMConfig) RequiresAPIKey() bool {
+	return l.Provider != "ollama"
+}
+
+// IsConfigured reports whether commit messages can be generated, either
+// because an API key is configured or because the provider needs none
+func (l LLMConfig) IsConfigured() bool {
+	return l.HasAPIKey() || !l.RequiresAPIKey()
+}
+
+// APIKeySource describes where the API key is read from, or "" if it is
+// stored in plain text or not configured
+func (l LLMConfig) APIKeySource() string {
+	switch {
+	case l.APIKey != "":
+		return ""
+	case l.APIKeyCmd != "":
+		return "command: " + l.APIKeyCmd
+	case l.APIKeyFile != "":
+		return "file: " + l.APIKeyFile
+	case l.APIKeyKeyring != "":
+		return "keyring: " + l.APIKeyKeyring
+	}
+	return ""
+}
+
+// ResolveAPIKey returns the API key, running the configured command or
+// reading the configured file or keyring entry if it is not stored in plain text
+func (l LLMConfig) ResolveAPIKey(ctx context.Context) (string, error) {
+	switch {
+	case l.APIKey != "":
+		return l.APIKey, nil
+	case l.APIKeyCmd != "":
+		return apiKeyFromCommand(ctx, l.APIKeyCmd)
+	case l.APIKeyFile != "":
+		return apiKeyFromFile(l.APIKeyFile)
+	case l.APIKeyKeyring != "":
+		return KeyringGet(ctx, l.APIKeyKeyring)
+	}
+	return "", fmt.Errorf("no API key configured")
+}
+
+// apiKeyFromCommand runs a shell command and uses the first line of its output
+func apiKeyFromCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := waitCommand(ctx, cmd.Output)
+	if err != nil {
+		return "", fmt.Errorf("api_key_cmd failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	key := firstLine(string(output))
+	if key == "" {
+		return "", fmt.Errorf("api_key_cmd produced no output")
+	}
+	return key, nil
+}
+
+// waitCommand returns the result of run, such as a command's Output, or an
+// error once ctx is done. The command itself is killed by its context, but
+// children it started can keep its output open, so run is not waited for.
+func waitCommand(ctx context.Context, run func() ([]byte, error)) ([]byte, error) {
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := run()
+		done <- result{output, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.output, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting: %w", ctx.Err())
+	}
+}
+
+// apiKeyFromFile reads the API key from the first line of a file
+func apiKeyFromFile(path string) (string, error) {
+	path, err := ExpandHome(path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read api_key_file: %w", err)
+	}
+
+	key := firstLine(string(data))
+	if key == "" {
+		return "", fmt.Errorf("api_key_file %s is empty", path)
+	}
+	return key, nil
+}
+
+// ExpandHome replaces a leading ~/ in path with the user's home directory
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return homeDir + path[1:], nil
+}
+
+// firstLine returns the first line of s without surrounding whitespace
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// KeyringAvailable reports whether a system keyring tool is installed
+func KeyringAvailable() bool {
+	switch runtime.GOOS {
+	case "linux":
+		_, err := exec.LookPath("secret-tool")
+		return err == nil
+	case "darwin":
+		_, err := exec.LookPath("security")
+		return err == nil
+	}
+	return false
+}
+
+// KeyringGet reads a secret stored under account in the system keyring
+func KeyringGet(ctx context.Context, account string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		// Secret Service via libsecret
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", keyringService, "account", account)
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	default:
+		return "", fmt.Errorf("system keyring is not supported on %s", runtime.GOOS)
+	}
+
+	output, err := waitCommand(ctx, cmd.Output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q from system keyring: %w", account, err)
+	}
+
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", fmt.Errorf("no secret for %q in system keyring", account)
+	}
+	return key, nil
+}
+
+// KeyringSet stores a secret under account in the system keyring
+func KeyringSet(ctx context.Context, account, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		// secret-tool reads the secret from stdin so it never appears in the process list
+		cmd = exec.CommandContext(ctx, "secret-tool", "store", "--label=commitmonk "+account, "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	case "darwin":
+		// -w without a value, last, makes security prompt for the secret and
+		// then for it again, so it too reads the secret from stdin
+		cmd = exec.CommandContext(ctx, "security", "add-generic-password", "-U", "-s", keyringService, "-a", account, "-w")
+		cmd.Stdin = strings.NewReader(secret + "\n" + secret + "\n")
+	default:
+		return fmt.Errorf("system keyring is not supported on %s", runtime.GOOS)
+	}
+
+	if output, err := waitCommand(ctx, cmd.CombinedOutput); err != nil {
+		return fmt.Errorf("failed to store %q in system keyring: %w: %s", account, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
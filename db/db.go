@@ -16,6 +16,111 @@ type Task struct {
 	AutoPush        bool
 	StaticMsg       string
 	ExcludePatterns string
+	Watch           bool
+	Settle          string
+	Cron            string
+	Timezone        string
+	Overlap         string
+	// PromptTemplate is a prompt template file overriding the configured one
+	PromptTemplate string
+	// Fallback is FallbackHeuristic to commit with a generated message when
+	// neither the LLM nor a static message is available, or FallbackNone
+	Fallback string
+	// SkipLLMOnSecrets keeps diffs containing secrets away from the LLM
+	// entirely instead of sending them redacted
+	SkipLLMOnSecrets bool
+	// SecretPolicy is what happens when staged changes introduce secrets:
+	// SecretPolicyBlock, SecretPolicyUnstage or SecretPolicyOff
+	SecretPolicy string
+	// Author is a "Name <email>" identity overriding the one in git config
+	Author string
+	// Attribution credits commitmonk in commits made under the author's
+	// identity: AttributionNone, AttributionCoAuthor or AttributionCommitter
+	Attribution string
+	// Snapshot commits to a snapshot ref from a temporary index instead of
+	// to the checked-out branch, leaving HEAD and the index untouched
+	Snapshot bool
+	// SyncStrategy is how commits pushed by others are handled before an
+	// auto-push: SyncFailFast, SyncRebase or SyncMerge
+	SyncStrategy string
+	// PushRemote is the remote auto-push pushes to; "" follows git config
+	PushRemote string
+	// PushBranch is the remote branch auto-push updates; "" follows the
+	// upstream, or the local branch's name when there is none
+	PushBranch string
+	// Mirrors are comma-separated remotes that are pushed to as well
+	Mirrors string
+}
+
+// Overlap policies for a task that comes due while its previous run is still in progress
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+)
+
+// Fallbacks for a task that has no commit message from the LLM or a static message
+const (
+	FallbackHeuristic = "heuristic"
+	FallbackNone      = "none"
+)
+
+// Secret policies for staged changes that introduce secrets
+const (
+	// SecretPolicyBlock refuses to commit anything
+	SecretPolicyBlock = "block"
+	// SecretPolicyUnstage unstages the files with secrets and commits the rest
+	SecretPolicyUnstage = "unstage"
+	// SecretPolicyOff does not scan staged changes
+	SecretPolicyOff = "off"
+)
+
+// Attributions of commitmonk in the commits it makes
+const (
+	// AttributionNone leaves commitmonk out of the commit
+	AttributionNone = "none"
+	// AttributionCoAuthor adds a Co-authored-by trailer
+	AttributionCoAuthor = "co-author"
+	// AttributionCommitter records commitmonk as the committer
+	AttributionCommitter = "committer"
+)
+
+// Sync strategies for a remote branch that has moved on before an auto-push
+const (
+	// SyncFailFast does not push and records the run as diverged
+	SyncFailFast = "fail-fast"
+	// SyncRebase rebases local commits onto the remote branch
+	SyncRebase = "rebase"
+	// SyncMerge merges the remote branch into the local one
+	SyncMerge = "merge"
+)
+
+// taskColumns lists the tasks table columns in the order scanTask reads them
+const taskColumns = "id, path, every, auto_add, auto_push, static_msg, exclude_patterns, watch, settle, cron, timezone, overlap, prompt_template, fallback, skip_llm_on_secrets, secret_policy, author, attribution, snapshot, sync_strategy, push_remote, push_branch, mirrors"
+
+// columnMigration is a column added to a table after its initial schema
+type columnMigration struct {
+	name       string
+	definition string
+}
+
+// taskMigrations lists columns added to the tasks table after its initial schema
+var taskMigrations = []columnMigration{
+	{"watch", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"settle", "TEXT NOT NULL DEFAULT ''"},
+	{"cron", "TEXT NOT NULL DEFAULT ''"},
+	{"timezone", "TEXT NOT NULL DEFAULT ''"},
+	{"overlap", "TEXT NOT NULL DEFAULT 'skip'"},
+	{"prompt_template", "TEXT NOT NULL DEFAULT ''"},
+	{"fallback", "TEXT NOT NULL DEFAULT 'heuristic'"},
+	{"skip_llm_on_secrets", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"secret_policy", "TEXT NOT NULL DEFAULT 'block'"},
+	{"author", "TEXT NOT NULL DEFAULT ''"},
+	{"attribution", "TEXT NOT NULL DEFAULT 'none'"},
+	{"snapshot", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"sync_strategy", "TEXT NOT NULL DEFAULT 'fail-fast'"},
+	{"push_remote", "TEXT NOT NULL DEFAULT ''"},
+	{"push_branch", "TEXT NOT NULL DEFAULT ''"},
+	{"mirrors", "TEXT NOT NULL DEFAULT ''"},
 }
 
 // DB wraps the SQLite database connection
@@ -30,6 +135,14 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// The scheduler records runs from several goroutines; serialize access
+	// through a single connection and wait on locks held by other processes
+	conn.SetMaxOpenConns(1)
+	if _, err := conn.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to configure database: %w", err)
+	}
+
 	// Create tasks table if it doesn't exist
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS tasks (
@@ -48,11 +161,104 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
+	if err := migrateTable(conn, "tasks", taskMigrations); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Create runs table if it doesn't exist
+	if _, err := conn.Exec(createRunsSQL); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create runs table: %w", err)
+	}
+
+	if err := migrateTable(conn, "runs", runMigrations); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	return &DB{conn: conn}, nil
 }
 
+// migrateTable adds any columns missing from a table created by an older version
+func migrateTable(conn *sql.DB, table string, migrations []columnMigration) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s schema: %w", table, err)
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    bool
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan %s schema: %w", table, err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, column := range migrations {
+		if existing[column.name] {
+			continue
+		}
+		alterSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.name, column.definition)
+		if _, err := conn.Exec(alterSQL); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column.name, err)
+		}
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask reads a task selected with taskColumns
+func scanTask(row rowScanner) (Task, error) {
+	var task Task
+	err := row.Scan(
+		&task.ID,
+		&task.Path,
+		&task.Every,
+		&task.AutoAdd,
+		&task.AutoPush,
+		&task.StaticMsg,
+		&task.ExcludePatterns,
+		&task.Watch,
+		&task.Settle,
+		&task.Cron,
+		&task.Timezone,
+		&task.Overlap,
+		&task.PromptTemplate,
+		&task.Fallback,
+		&task.SkipLLMOnSecrets,
+		&task.SecretPolicy,
+		&task.Author,
+		&task.Attribution,
+		&task.Snapshot,
+		&task.SyncStrategy,
+		&task.PushRemote,
+		&task.PushBranch,
+		&task.Mirrors,
+	)
+	return task, err
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
+	if db.conn == nil {
+		return nil
+	}
 	return db.conn.Close()
 }
 
@@ -61,8 +267,8 @@ func (db *DB) AddTask(task Task) error {
 	// Replace existing task if path already exists
 	stmt, err := db.conn.Prepare(`
 		INSERT OR REPLACE INTO tasks 
-		(path, every, auto_add, auto_push, static_msg, exclude_patterns)
-		VALUES (?, ?, ?, ?, ?, ?)
+		(path, every, auto_add, auto_push, static_msg, exclude_patterns, watch, settle, cron, timezone, overlap, prompt_template, fallback, skip_llm_on_secrets, secret_policy, author, attribution, snapshot, sync_strategy, push_remote, push_branch, mirrors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
@@ -76,6 +282,22 @@ func (db *DB) AddTask(task Task) error {
 		task.AutoPush,
 		task.StaticMsg,
 		task.ExcludePatterns,
+		task.Watch,
+		task.Settle,
+		task.Cron,
+		task.Timezone,
+		task.Overlap,
+		task.PromptTemplate,
+		task.Fallback,
+		task.SkipLLMOnSecrets,
+		task.SecretPolicy,
+		task.Author,
+		task.Attribution,
+		task.Snapshot,
+		task.SyncStrategy,
+		task.PushRemote,
+		task.PushBranch,
+		task.Mirrors,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to add task: %w", err)
@@ -136,10 +358,7 @@ func (db *DB) RemoveTaskByID(id int64) error {
 
 // GetAllTasks retrieves all tasks from the database
 func (db *DB) GetAllTasks() ([]Task, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, path, every, auto_add, auto_push, static_msg, exclude_patterns
-		FROM tasks
-	`)
+	rows, err := db.conn.Query("SELECT " + taskColumns + " FROM tasks")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tasks: %w", err)
 	}
@@ -147,16 +366,7 @@ func (db *DB) GetAllTasks() ([]Task, error) {
 
 	var tasks []Task
 	for rows.Next() {
-		var task Task
-		err := rows.Scan(
-			&task.ID,
-			&task.Path,
-			&task.Every,
-			&task.AutoAdd,
-			&task.AutoPush,
-			&task.StaticMsg,
-			&task.ExcludePatterns,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -172,26 +382,13 @@ func (db *DB) GetAllTasks() ([]Task, error) {
 
 // GetTask retrieves a specific task by path
 func (db *DB) GetTask(path string) (*Task, error) {
-	stmt, err := db.conn.Prepare(`
-		SELECT id, path, every, auto_add, auto_push, static_msg, exclude_patterns
-		FROM tasks
-		WHERE path = ?
-	`)
+	stmt, err := db.conn.Prepare("SELECT " + taskColumns + " FROM tasks WHERE path = ?")
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	var task Task
-	err = stmt.QueryRow(path).Scan(
-		&task.ID,
-		&task.Path,
-		&task.Every,
-		&task.AutoAdd,
-		&task.AutoPush,
-		&task.StaticMsg,
-		&task.ExcludePatterns,
-	)
+	task, err := scanTask(stmt.QueryRow(path))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("no task found for path: %s", path)
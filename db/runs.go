@@ -0,0 +1,195 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Run outcomes recorded in the runs table
+const (
+	OutcomeCommitted        = "committed"
+	OutcomePushed           = "pushed"
+	OutcomeSkippedNoChanges = "skipped-no-changes"
+	OutcomeFailed           = "failed"
+	OutcomeSkippedOverlap   = "skipped-overlap"
+	OutcomeQueuedOverlap    = "queued-overlap"
+	OutcomeInterrupted      = "interrupted"
+	OutcomeSkippedBranch    = "skipped-branch"
+	// OutcomeBlockedSecrets means staged changes introduced secrets and nothing was committed
+	OutcomeBlockedSecrets = "blocked-secrets"
+	// OutcomeDeferred means the LLM failed temporarily and the commit waits for the next run
+	OutcomeDeferred = "deferred"
+	// OutcomeSyncDiverged means the commit was not pushed because the remote
+	// branch had moved on and the task's sync strategy is fail-fast
+	OutcomeSyncDiverged = "sync-diverged"
+	// OutcomeSyncConflict means the commit was not pushed because integrating
+	// the remote branch conflicted; the sync was undone
+	OutcomeSyncConflict = "sync-conflict"
+)
+
+// Commit message sources recorded in the runs table
+const (
+	MessageSourceLLM    = "llm"
+	MessageSourceStatic = "static"
+	// MessageSourceHeuristic is a message derived offline from the staged changes
+	MessageSourceHeuristic = "heuristic"
+)
+
+// Run represents a single execution of a task
+type Run struct {
+	ID            int64     `json:"id"`
+	TaskID        int64     `json:"task_id"`
+	Path          string    `json:"path"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Outcome       string    `json:"outcome"`
+	CommitHash    string    `json:"commit_hash,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	MessageSource string    `json:"message_source,omitempty"`
+	Endpoint      string    `json:"endpoint,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	// Secrets lists the secrets found in staged changes, one per line
+	Secrets string `json:"secrets,omitempty"`
+	// Sync describes how remote commits were integrated before pushing
+	Sync string `json:"sync,omitempty"`
+}
+
+// RunFilter narrows the runs returned by GetRuns. Zero values match everything.
+type RunFilter struct {
+	TaskID  int64
+	Path    string
+	Since   time.Time
+	Outcome string
+	Limit   int
+}
+
+// createRunsSQL creates the runs table. The repository path is stored on
+// each run so history survives the task being removed.
+const createRunsSQL = `
+	CREATE TABLE IF NOT EXISTS runs (
+		id INTEGER PRIMARY KEY,
+		task_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP NOT NULL,
+		outcome TEXT NOT NULL,
+		commit_hash TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		message_source TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS runs_started_at ON runs (started_at);`
+
+// runMigrations lists columns added to the runs table after its initial schema
+var runMigrations = []columnMigration{
+	{"endpoint", "TEXT NOT NULL DEFAULT ''"},
+	{"secrets", "TEXT NOT NULL DEFAULT ''"},
+	{"sync", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// AddRun records a task execution
+func (db *DB) AddRun(run Run) error {
+	stmt, err := db.conn.Prepare(`
+		INSERT INTO runs
+		(task_id, path, started_at, finished_at, outcome, commit_hash, message, message_source, endpoint, error, secrets, sync)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(
+		run.TaskID,
+		run.Path,
+		run.StartedAt.UTC(),
+		run.FinishedAt.UTC(),
+		run.Outcome,
+		run.CommitHash,
+		run.Message,
+		run.MessageSource,
+		run.Endpoint,
+		run.Error,
+		run.Secrets,
+		run.Sync,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add run: %w", err)
+	}
+
+	return nil
+}
+
+// GetRuns retrieves runs matching the filter, most recent first
+func (db *DB) GetRuns(filter RunFilter) ([]Run, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if filter.TaskID != 0 {
+		conditions = append(conditions, "task_id = ?")
+		args = append(args, filter.TaskID)
+	}
+	if filter.Path != "" {
+		conditions = append(conditions, "path = ?")
+		args = append(args, filter.Path)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+
+	query := `
+		SELECT id, task_id, path, started_at, finished_at, outcome,
+		       commit_hash, message, message_source, endpoint, error, secrets, sync
+		FROM runs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		err := rows.Scan(
+			&run.ID,
+			&run.TaskID,
+			&run.Path,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.Outcome,
+			&run.CommitHash,
+			&run.Message,
+			&run.MessageSource,
+			&run.Endpoint,
+			&run.Error,
+			&run.Secrets,
+			&run.Sync,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", err)
+	}
+
+	return runs, nil
+}
@@ -2,14 +2,20 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/gobwas/glob"
 )
@@ -38,6 +44,20 @@ func NewRepoManager(repoPath string) (*RepoManager, error) {
 	}, nil
 }
 
+// CurrentBranch returns the short name of the checked-out branch, or "" when
+// HEAD is detached. The branch need not have commits yet.
+func (r *RepoManager) CurrentBranch() (string, error) {
+	// HEAD is read without resolving it, since an unborn branch does not exist yet
+	head, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", nil
+	}
+	return head.Target().Short(), nil
+}
+
 // HasChanges checks if the repository has any changes (staged or unstaged)
 func (r *RepoManager) HasChanges() (bool, error) {
 	wt, err := r.repo.Worktree()
@@ -78,8 +98,100 @@ func (r *RepoManager) HasStagedChanges() (bool, error) {
 	return false, nil
 }
 
-// StageChanges stages all changes except those matching exclude patterns
-func (r *RepoManager) StageChanges(excludePatterns string) error {
+// Kinds of change to a staged file
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeRenamed  = "renamed"
+)
+
+// FileChange describes a staged change to a single file
+type FileChange struct {
+	Path string
+	// OldPath is the previous path of a renamed file
+	OldPath string
+	Kind    string
+}
+
+// StagedChanges lists the staged files and how they changed. Renames are
+// only detected when the git executable is available.
+func (r *RepoManager) StagedChanges(ctx context.Context) ([]FileChange, error) {
+	if _, err := exec.LookPath("git"); err == nil {
+		output, err := r.gitOutput(ctx, nil, "diff", "--cached", "--name-status", "-M", "-z")
+		if err != nil {
+			return nil, err
+		}
+		return parseNameStatus(output), nil
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	var changes []FileChange
+	for filePath, fileStatus := range status {
+		switch fileStatus.Staging {
+		case git.Added, git.Copied:
+			changes = append(changes, FileChange{Path: filePath, Kind: ChangeAdded})
+		case git.Deleted:
+			changes = append(changes, FileChange{Path: filePath, Kind: ChangeDeleted})
+		case git.Renamed:
+			changes = append(changes, FileChange{Path: filePath, Kind: ChangeRenamed})
+		case git.Modified, git.UpdatedButUnmerged:
+			changes = append(changes, FileChange{Path: filePath, Kind: ChangeModified})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// parseNameStatus parses `git diff --name-status -z` output
+func parseNameStatus(output string) []FileChange {
+	fields := strings.Split(output, "\x00")
+
+	var changes []FileChange
+	for i := 0; i+1 < len(fields); i += 2 {
+		status := fields[i]
+		if status == "" {
+			break
+		}
+
+		change := FileChange{Path: fields[i+1], Kind: ChangeModified}
+		switch status[0] {
+		case 'A':
+			change.Kind = ChangeAdded
+		case 'D':
+			change.Kind = ChangeDeleted
+		case 'R', 'C':
+			// Renames and copies are followed by both the old and the new path
+			if i+2 < len(fields) {
+				change.OldPath, change.Path = fields[i+1], fields[i+2]
+				i++
+			}
+			change.Kind = ChangeRenamed
+			if status[0] == 'C' {
+				change.Kind = ChangeAdded
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// StageChanges stages all changes except those matching exclude patterns.
+// Cancelling ctx prevents staging from starting; once started it runs to completion.
+func (r *RepoManager) StageChanges(ctx context.Context, excludePatterns string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	wt, err := r.repo.Worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
@@ -92,34 +204,24 @@ func (r *RepoManager) StageChanges(excludePatterns string) error {
 	}
 
 	// If exclusion patterns are provided, unstage matching files
-	if excludePatterns != "" {
-		patterns := strings.Split(excludePatterns, ",")
-		for _, pattern := range patterns {
-			pattern = strings.TrimSpace(pattern)
-			if pattern == "" {
-				continue
-			}
+	excludes, err := CompileExcludePatterns(excludePatterns)
+	if err != nil {
+		return err
+	}
+	if len(excludes) == 0 {
+		return nil
+	}
 
-			g, err := glob.Compile(pattern)
-			if err != nil {
-				return fmt.Errorf("invalid exclude pattern '%s': %w", pattern, err)
-			}
+	status, err := wt.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
 
-			status, err := wt.Status()
+	for filePath := range status {
+		if MatchesExclude(excludes, filePath) {
+			err = wt.RemoveGlob(filePath)
 			if err != nil {
-				return fmt.Errorf("failed to get status: %w", err)
-			}
-
-			for filePath := range status {
-				if g.Match(filePath) {
-					// Create a reset option with file path in the Paths slice
-					// Note: We can't directly pass the file path to Reset,
-					// but need to use git.ResetOptions as documented
-					err = wt.RemoveGlob(filePath)
-					if err != nil {
-						return fmt.Errorf("failed to unstage file %s: %w", filePath, err)
-					}
-				}
+				return fmt.Errorf("failed to unstage file %s: %w", filePath, err)
 			}
 		}
 	}
@@ -127,12 +229,45 @@ func (r *RepoManager) StageChanges(excludePatterns string) error {
 	return nil
 }
 
+// CompileExcludePatterns parses a comma-separated list of glob patterns
+func CompileExcludePatterns(excludePatterns string) ([]glob.Glob, error) {
+	var globs []glob.Glob
+	for _, pattern := range strings.Split(excludePatterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern '%s': %w", pattern, err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+// MatchesExclude reports whether a repository-relative path matches any exclude pattern
+func MatchesExclude(excludes []glob.Glob, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, g := range excludes {
+		if g.Match(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetDiff returns the diff of staged changes
-func (r *RepoManager) GetDiff() (string, error) {
+func (r *RepoManager) GetDiff(ctx context.Context) (string, error) {
 	// First try using git executable if available
-	if diffStr, err := r.getSystemGitDiff(); err == nil {
+	diffStr, err := r.getSystemGitDiff(ctx)
+	if err == nil {
 		return diffStr, nil
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
 
 	// Fall back to go-git implementation if git executable is not available
 	wt, err := r.repo.Worktree()
@@ -176,7 +311,7 @@ func (r *RepoManager) GetDiff() (string, error) {
 }
 
 // getSystemGitDiff attempts to get diff using the git executable
-func (r *RepoManager) getSystemGitDiff() (string, error) {
+func (r *RepoManager) getSystemGitDiff(ctx context.Context) (string, error) {
 	// Check if git is installed
 	_, err := exec.LookPath("git")
 	if err != nil {
@@ -184,7 +319,7 @@ func (r *RepoManager) getSystemGitDiff() (string, error) {
 	}
 
 	// Execute git diff --staged to get the diff of staged changes
-	cmd := exec.Command("git", "diff", "--staged")
+	cmd := exec.CommandContext(ctx, "git", "diff", "--staged")
 	cmd.Dir = r.path // Set working directory to repository path
 	output, err := cmd.Output()
 	if err != nil {
@@ -194,7 +329,7 @@ func (r *RepoManager) getSystemGitDiff() (string, error) {
 	// If there's no diff, we might need to check if there are unstaged changes
 	if len(output) == 0 {
 		// Try getting unstaged changes
-		cmd = exec.Command("git", "diff")
+		cmd = exec.CommandContext(ctx, "git", "diff")
 		cmd.Dir = r.path
 		output, err = cmd.Output()
 		if err != nil {
@@ -205,68 +340,246 @@ func (r *RepoManager) getSystemGitDiff() (string, error) {
 	return string(output), nil
 }
 
-// Commit creates a new commit with the given message
-func (r *RepoManager) Commit(message string) error {
+// PreviewDiff returns the diff a run would commit without touching the
+// index: with autoAdd, all changes outside the exclude patterns are staged
+// into a temporary copy of the index first. It requires the git executable.
+func (r *RepoManager) PreviewDiff(ctx context.Context, autoAdd bool, excludePatterns string) (string, error) {
+	if !autoAdd {
+		return r.getSystemGitDiff(ctx)
+	}
+
+	tmpIndex, err := r.tempIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpIndex)
+
+	env := []string{"GIT_INDEX_FILE=" + tmpIndex}
+	if _, err := r.gitOutput(ctx, env, "add", "-A", "."); err != nil {
+		return "", err
+	}
+
+	names, err := r.gitOutput(ctx, env, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return "", err
+	}
+
+	excludes, err := CompileExcludePatterns(excludePatterns)
+	if err != nil {
+		return "", err
+	}
+	args := []string{"diff", "--cached", "--"}
+	for _, name := range strings.Split(names, "\x00") {
+		if name != "" && !MatchesExclude(excludes, name) {
+			args = append(args, name)
+		}
+	}
+	if len(args) == 3 {
+		return "", nil
+	}
+
+	return r.gitOutput(ctx, env, args...)
+}
+
+// tempIndex copies the index to a temporary file and returns its path. The
+// caller removes the file.
+func (r *RepoManager) tempIndex(ctx context.Context) (string, error) {
+	indexPath, err := r.gitOutput(ctx, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(r.path, indexPath)
+	}
+
+	tmpIndex, err := os.CreateTemp("", "commitmonk-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	defer tmpIndex.Close()
+
+	// A missing index just means nothing has been staged yet, but git
+	// rejects an empty file, so it gets an index without entries
+	indexFile, err := os.Open(indexPath)
+	if os.IsNotExist(err) {
+		err = index.NewEncoder(tmpIndex).Encode(&index.Index{Version: 2})
+	} else if err == nil {
+		_, err = io.Copy(tmpIndex, indexFile)
+		indexFile.Close()
+	}
+	if err != nil {
+		os.Remove(tmpIndex.Name())
+		return "", fmt.Errorf("failed to copy index: %w", err)
+	}
+	return tmpIndex.Name(), nil
+}
+
+// gitOutput runs the git executable in the repository with extra environment
+// variables and returns its trimmed output
+func (r *RepoManager) gitOutput(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.path
+	cmd.Env = append(os.Environ(), env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// RecentSubjects returns the subject lines of up to n commits reachable from
+// HEAD, newest first. A repository without commits has none.
+func (r *RepoManager) RecentSubjects(n int) ([]string, error) {
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	commits, err := r.repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit log: %w", err)
+	}
+	defer commits.Close()
+
+	var subjects []string
+	for len(subjects) < n {
+		commit, err := commits.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit log: %w", err)
+		}
+		subject := strings.TrimSpace(commit.Message)
+		if i := strings.IndexByte(subject, '\n'); i >= 0 {
+			subject = strings.TrimSpace(subject[:i])
+		}
+		subjects = append(subjects, subject)
+	}
+	return subjects, nil
+}
+
+// Commit creates a new commit with the given message and returns its hash.
+// The author is opts.Author or the identity from git config. When git config
+// enables commit signing, the commit is created and signed by the git
+// executable. Cancelling ctx prevents the commit from starting; once started
+// it runs to completion.
+func (r *RepoManager) Commit(ctx context.Context, message string, opts CommitOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	wt, err := r.repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return "", fmt.Errorf("failed to get worktree: %w", err)
 	}
 
 	// Check if there are staged changes
 	hasStagedChanges, err := r.HasStagedChanges()
 	if err != nil {
-		return fmt.Errorf("failed to check for staged changes: %w", err)
+		return "", fmt.Errorf("failed to check for staged changes: %w", err)
 	}
 
 	if !hasStagedChanges {
 		// Auto-stage all changes if no staged changes exist
 		if err := wt.AddGlob("."); err != nil {
-			return fmt.Errorf("failed to stage changes: %w", err)
+			return "", fmt.Errorf("failed to stage changes: %w", err)
 		}
 
 		// Check again if we have staged changes after auto-staging
 		hasStagedChanges, err = r.HasStagedChanges()
 		if err != nil {
-			return fmt.Errorf("failed to check for staged changes: %w", err)
+			return "", fmt.Errorf("failed to check for staged changes: %w", err)
 		}
 
 		if !hasStagedChanges {
-			return fmt.Errorf("no staged changes to commit")
+			return "", fmt.Errorf("no staged changes to commit")
 		}
 	}
 
-	_, err = wt.Commit(message, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "Commitmonk",
-			Email: "commitmonk@automated.tool",
-			When:  time.Now(),
-		},
+	author, committer, err := r.identities(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	message = addTrailers(message, opts)
+
+	signing, err := r.SigningConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if signing.Enabled {
+		return r.commitSigned(message, author, committer)
+	}
+
+	now := time.Now()
+	hash, err := wt.Commit(message, &git.CommitOptions{
+		Author:    &object.Signature{Name: author.Name, Email: author.Email, When: now},
+		Committer: &object.Signature{Name: committer.Name, Email: committer.Email, When: now},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %w", err)
+		return "", fmt.Errorf("failed to create commit: %w", err)
 	}
 
-	return nil
+	return hash.String(), nil
 }
 
-// Push pushes commits to the remote repository
-func (r *RepoManager) Push() error {
-	// Get the current branch
-	head, err := r.repo.Head()
+// commitSigned commits the index with the git executable, which signs the
+// commit as configured by gpg.format and user.signingkey
+func (r *RepoManager) commitSigned(message string, author, committer Identity) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("commit signing requires the git executable: %w", err)
+	}
+
+	env := identityEnv(author, committer)
+	// A started commit is not cancelled. Like go-git, skip hooks and keep
+	// the message as is.
+	ctx := context.Background()
+	if _, err := r.gitOutput(ctx, env, "commit", "--quiet", "--no-verify", "--cleanup=verbatim", "--gpg-sign", "--message", message); err != nil {
+		return "", fmt.Errorf("failed to create signed commit: %w", err)
+	}
+
+	hash, err := r.gitOutput(ctx, nil, "rev-parse", "HEAD")
 	if err != nil {
-		return fmt.Errorf("failed to get HEAD: %w", err)
+		return "", fmt.Errorf("failed to read signed commit: %w", err)
 	}
+	return hash, nil
+}
 
-	// Create proper RefSpec
-	refSpec := config.RefSpec(head.Name().String() + ":" + head.Name().String())
+// ResetIndex unstages everything, restoring the index to HEAD without
+// touching the working tree
+func (r *RepoManager) ResetIndex() error {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
 
-	// Push to remote
-	err = r.repo.Push(&git.PushOptions{
-		RefSpecs: []config.RefSpec{refSpec},
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to push: %w", err)
+	if err := wt.Reset(&git.ResetOptions{Mode: git.MixedReset}); err != nil {
+		return fmt.Errorf("failed to reset index: %w", err)
 	}
 
 	return nil
 }
+
+// Push pushes the checked-out branch to the target's branch on its remote,
+// sets the upstream if the branch has none, and then pushes to the mirrors.
+// If only the last two steps fail, the error wraps ErrPushIncomplete.
+func (r *RepoManager) Push(ctx context.Context, target PushTarget) error {
+	refSpec := config.RefSpec(plumbing.NewBranchReferenceName(target.local).String() + ":" + plumbing.NewBranchReferenceName(target.Branch).String())
+	if err := r.pushRefSpec(ctx, target.Remote, refSpec); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", target, err)
+	}
+
+	var failures []string
+	if target.SetUpstream {
+		if err := r.setUpstream(target); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return incompletePush(append(failures, r.pushMirrors(ctx, target, refSpec)...))
+}
@@ -0,0 +1,206 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5/config"
+)
+
+// Identity is the name and email of a commit author or committer
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// BotIdentity is the identity commits are made with when git has none configured
+var BotIdentity = Identity{Name: "Commitmonk", Email: "commitmonk@automated.tool"}
+
+// String formats the identity as "Name <email>"
+func (i Identity) String() string {
+	return fmt.Sprintf("%s <%s>", i.Name, i.Email)
+}
+
+// IsZero reports whether the identity is unset
+func (i Identity) IsZero() bool {
+	return i.Name == "" && i.Email == ""
+}
+
+// ParseIdentity parses an identity written as "Name <email>"
+func ParseIdentity(s string) (Identity, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name == "" {
+		return Identity{}, fmt.Errorf("invalid identity %q: expected \"Name <email>\"", s)
+	}
+	return Identity{Name: addr.Name, Email: addr.Address}, nil
+}
+
+// CommitOptions controls who a commit is attributed to
+type CommitOptions struct {
+	// Author overrides the user.name and user.email from git config
+	Author Identity
+	// Committer is recorded as the committer when set; otherwise the author is
+	Committer Identity
+	// CoAuthors are credited with Co-authored-by trailers
+	CoAuthors []Identity
+	// Run, when set, identifies the commitmonk run making the commit in a
+	// RunTrailer
+	Run string
+}
+
+// RunTrailer is the trailer that marks commits made by a commitmonk run,
+// whatever identity they were made with
+const RunTrailer = "Commitmonk-Run"
+
+// Signing describes the commit signing settings from git config
+type Signing struct {
+	// Enabled is commit.gpgsign
+	Enabled bool
+	// Format is gpg.format: openpgp, ssh or x509
+	Format string
+	// Key is user.signingkey
+	Key string
+}
+
+// ConfiguredIdentity returns user.name and user.email from the repository's
+// git config, including the global and system config. It returns BotIdentity
+// when git has no identity configured.
+func (r *RepoManager) ConfiguredIdentity(ctx context.Context) (Identity, error) {
+	name, err := r.configValue(ctx, "user.name")
+	if err != nil {
+		return Identity{}, err
+	}
+	email, err := r.configValue(ctx, "user.email")
+	if err != nil {
+		return Identity{}, err
+	}
+	if name == "" || email == "" {
+		return BotIdentity, nil
+	}
+	return Identity{Name: name, Email: email}, nil
+}
+
+// SigningConfig returns the repository's commit signing settings
+func (r *RepoManager) SigningConfig(ctx context.Context) (Signing, error) {
+	var signing Signing
+	gpgSign, err := r.configValue(ctx, "commit.gpgsign")
+	if err != nil {
+		return signing, err
+	}
+	switch strings.ToLower(gpgSign) {
+	case "true", "yes", "on", "1":
+		signing.Enabled = true
+	}
+
+	if signing.Format, err = r.configValue(ctx, "gpg.format"); err != nil {
+		return signing, err
+	}
+	if signing.Format == "" {
+		signing.Format = "openpgp"
+	}
+	if signing.Key, err = r.configValue(ctx, "user.signingkey"); err != nil {
+		return signing, err
+	}
+	return signing, nil
+}
+
+// configValue reads a git config value as the git executable sees it, so
+// that include files and conditional includes apply. Without the git
+// executable it falls back to the repository and global config files.
+// An unset key is "".
+func (r *RepoManager) configValue(ctx context.Context, key string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return r.goGitConfigValue(key)
+	}
+
+	value, err := r.gitOutput(ctx, nil, "config", "--get", key)
+	if isExitCode(err, 1) {
+		return "", nil
+	}
+	return value, err
+}
+
+// goGitConfigValue reads a "section.name" or "section.subsection.name"
+// value with go-git from the repository, global and system config, in that
+// order of precedence
+func (r *RepoManager) goGitConfigValue(key string) (string, error) {
+	first, last := strings.IndexByte(key, '.'), strings.LastIndexByte(key, '.')
+	section, option := key[:first], key[last+1:]
+	var subsection string
+	if first < last {
+		subsection = key[first+1 : last]
+	}
+	lookup := func(cfg *config.Config) string {
+		s := cfg.Raw.Section(section)
+		if subsection != "" {
+			return s.Subsection(subsection).Option(option)
+		}
+		return s.Option(option)
+	}
+
+	local, err := r.repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("failed to read git config: %w", err)
+	}
+	if value := lookup(local); value != "" {
+		return value, nil
+	}
+
+	for _, scope := range []config.Scope{config.GlobalScope, config.SystemScope} {
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			return "", fmt.Errorf("failed to read git config: %w", err)
+		}
+		if value := lookup(cfg); value != "" {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// identities returns the author and committer of a commit made with opts
+func (r *RepoManager) identities(ctx context.Context, opts CommitOptions) (Identity, Identity, error) {
+	author := opts.Author
+	if author.IsZero() {
+		var err error
+		author, err = r.ConfiguredIdentity(ctx)
+		if err != nil {
+			return Identity{}, Identity{}, err
+		}
+	}
+	committer := opts.Committer
+	if committer.IsZero() {
+		committer = author
+	}
+	return author, committer, nil
+}
+
+// identityEnv sets the author and committer of a commit made by the git executable
+func identityEnv(author, committer Identity) []string {
+	return []string{
+		"GIT_AUTHOR_NAME=" + author.Name,
+		"GIT_AUTHOR_EMAIL=" + author.Email,
+		"GIT_COMMITTER_NAME=" + committer.Name,
+		"GIT_COMMITTER_EMAIL=" + committer.Email,
+	}
+}
+
+// addTrailers appends the Co-authored-by and run trailers of opts to a commit message
+func addTrailers(message string, opts CommitOptions) string {
+	if len(opts.CoAuthors) == 0 && opts.Run == "" {
+		return message
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(message, "\n"))
+	b.WriteString("\n\n")
+	for _, coAuthor := range opts.CoAuthors {
+		fmt.Fprintf(&b, "Co-authored-by: %s\n", coAuthor)
+	}
+	if opts.Run != "" {
+		fmt.Fprintf(&b, "%s: %s\n", RunTrailer, opts.Run)
+	}
+	return b.String()
+}
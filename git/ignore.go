@@ -0,0 +1,98 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// IgnoreMatcher returns a matcher for the paths git ignores in the working
+// tree at root, from the system and global excludes files,
+// .git/info/exclude and every .gitignore. Ignored directories are not
+// searched for further .gitignore files.
+func IgnoreMatcher(root string) (gitignore.Matcher, error) {
+	rootFS := osfs.New("/")
+	// Unreadable global config is ignored, as go-git's own status does
+	patterns, _ := gitignore.LoadSystemPatterns(rootFS)
+	global, _ := gitignore.LoadGlobalPatterns(rootFS)
+	patterns = append(patterns, global...)
+
+	exclude, err := readIgnoreFile(filepath.Join(root, ".git", "info", "exclude"), nil)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, exclude...)
+
+	patterns, err = readIgnoreFiles(root, nil, patterns)
+	if err != nil {
+		return nil, err
+	}
+	return gitignore.NewMatcher(patterns), nil
+}
+
+// readIgnoreFiles appends the patterns of the .gitignore in dir, a path
+// relative to root, and of the directories below it that are not ignored
+func readIgnoreFiles(root string, dir []string, patterns []gitignore.Pattern) ([]gitignore.Pattern, error) {
+	path := filepath.Join(append([]string{root}, dir...)...)
+	filePatterns, err := readIgnoreFile(filepath.Join(path, ".gitignore"), dir)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, filePatterns...)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if len(dir) > 0 {
+			// Directories can disappear while we walk them
+			return patterns, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	matcher := gitignore.NewMatcher(patterns)
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == ".git" {
+			continue
+		}
+		sub := append(dir[:len(dir):len(dir)], entry.Name())
+		if matcher.Match(sub, true) {
+			continue
+		}
+		if patterns, err = readIgnoreFiles(root, sub, patterns); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
+
+// readIgnoreFile parses an ignore file whose patterns apply below dir. A
+// missing file has no patterns.
+func readIgnoreFile(path string, dir []string) ([]gitignore.Pattern, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, dir))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,172 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrPushIncomplete means the push itself succeeded, but setting the
+// upstream or pushing to a mirror failed
+var ErrPushIncomplete = errors.New("pushed, but not completely")
+
+// PushTarget is where Push sends the checked-out branch
+type PushTarget struct {
+	// Remote is the remote that is pushed to
+	Remote string
+	// Branch is the branch updated on Remote
+	Branch string
+	// Mirrors are further remotes the same branch is pushed to
+	Mirrors []string
+	// SetUpstream makes Remote/Branch the upstream of the local branch after
+	// pushing, because it has none yet
+	SetUpstream bool
+
+	// local is the checked-out branch
+	local string
+}
+
+// String formats the target as remote/branch
+func (t PushTarget) String() string {
+	return t.Remote + "/" + t.Branch
+}
+
+// ResolvePushTarget decides where the checked-out branch is pushed. An empty
+// remote is chosen as git push does: branch.<name>.pushRemote, then
+// remote.pushDefault, then the upstream's remote, then origin. An empty
+// branch follows the upstream when it is on that remote, unless push.default
+// is current or matching, and is otherwise the local branch's name. mirrors
+// is a comma-separated list of remotes.
+func (r *RepoManager) ResolvePushTarget(ctx context.Context, remote, branch, mirrors string) (PushTarget, error) {
+	local, err := r.CurrentBranch()
+	if err != nil {
+		return PushTarget{}, err
+	}
+	if local == "" {
+		return PushTarget{}, fmt.Errorf("pushing requires a checked-out branch")
+	}
+
+	upstreamRemote, err := r.configValue(ctx, "branch."+local+".remote")
+	if err != nil {
+		return PushTarget{}, err
+	}
+	upstreamMerge, err := r.configValue(ctx, "branch."+local+".merge")
+	if err != nil {
+		return PushTarget{}, err
+	}
+
+	if remote == "" {
+		for _, key := range []string{"branch." + local + ".pushRemote", "remote.pushDefault"} {
+			if remote, err = r.configValue(ctx, key); err != nil {
+				return PushTarget{}, err
+			}
+			if remote != "" {
+				break
+			}
+		}
+	}
+	if remote == "" {
+		remote = upstreamRemote
+	}
+	if remote == "" {
+		remote = git.DefaultRemoteName
+	}
+
+	if branch == "" {
+		pushDefault, err := r.configValue(ctx, "push.default")
+		if err != nil {
+			return PushTarget{}, err
+		}
+		switch {
+		case pushDefault == "nothing":
+			return PushTarget{}, fmt.Errorf("push.default is nothing, so a branch to push to must be given")
+		case pushDefault != "current" && pushDefault != "matching" &&
+			remote == upstreamRemote && strings.HasPrefix(upstreamMerge, "refs/heads/"):
+			branch = strings.TrimPrefix(upstreamMerge, "refs/heads/")
+		default:
+			branch = local
+		}
+	}
+
+	target := PushTarget{
+		Remote:      remote,
+		Branch:      branch,
+		SetUpstream: upstreamRemote == "",
+		local:       local,
+	}
+	for _, mirror := range strings.Split(mirrors, ",") {
+		if mirror = strings.TrimSpace(mirror); mirror != "" && mirror != remote {
+			target.Mirrors = append(target.Mirrors, mirror)
+		}
+	}
+	return target, nil
+}
+
+// HasRemote reports whether the repository has a remote with the given name
+func (r *RepoManager) HasRemote(name string) (bool, error) {
+	_, err := r.repo.Remote(name)
+	if err == git.ErrRemoteNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read remote %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// pushRefSpec pushes a refspec to a remote
+func (r *RepoManager) pushRefSpec(ctx context.Context, remote string, refSpec config.RefSpec) error {
+	err := r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{refSpec},
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// pushMirrors pushes a refspec to every mirror of target and returns why
+// each mirror that failed did
+func (r *RepoManager) pushMirrors(ctx context.Context, target PushTarget, refSpec config.RefSpec) []string {
+	var failures []string
+	for _, mirror := range target.Mirrors {
+		if err := r.pushRefSpec(ctx, mirror, refSpec); err != nil {
+			failures = append(failures, fmt.Sprintf("failed to push to mirror %s: %v", mirror, err))
+		}
+	}
+	return failures
+}
+
+// incompletePush returns an error wrapping ErrPushIncomplete for the steps
+// after a successful push that failed, or nil if there are none
+func incompletePush(failures []string) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPushIncomplete, strings.Join(failures, "; "))
+}
+
+// setUpstream makes the target's remote branch the upstream of the local branch
+func (r *RepoManager) setUpstream(target PushTarget) error {
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read git config: %w", err)
+	}
+	branch, ok := cfg.Branches[target.local]
+	if !ok {
+		branch = &config.Branch{Name: target.local}
+		cfg.Branches[target.local] = branch
+	}
+	branch.Remote = target.Remote
+	branch.Merge = plumbing.NewBranchReferenceName(target.Branch)
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to set upstream of %s: %w", target.local, err)
+	}
+	return nil
+}
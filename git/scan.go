@@ -0,0 +1,196 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/tejzpr/commitmonk/secrets"
+)
+
+// maxScanSize is the largest file scanned for secrets
+const maxScanSize = 1 << 20
+
+// ScanStaged scans the staged content of added, modified and renamed files
+// for secrets they introduce. Secrets the file already contained in HEAD and
+// findings listed in the repository's allowlist file are not reported.
+// Binary and very large files are skipped.
+func (r *RepoManager) ScanStaged(ctx context.Context, scanner *secrets.Scanner) ([]secrets.Finding, error) {
+	changes, err := r.StagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	headTree, err := r.headTree()
+	if err != nil {
+		return nil, err
+	}
+	return r.scanIndex(ctx, scanner, changes, idx, headTree)
+}
+
+// scanIndex scans the changes staged in idx relative to base, which is nil
+// when there is nothing to compare against
+func (r *RepoManager) scanIndex(ctx context.Context, scanner *secrets.Scanner, changes []FileChange, idx *index.Index, base *object.Tree) ([]secrets.Finding, error) {
+	allowlist, err := secrets.LoadAllowlist(filepath.Join(r.path, secrets.AllowlistFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []secrets.Finding
+	for _, change := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// The allowlist holds fingerprints, which look like secrets but are not
+		if change.Kind == ChangeDeleted || change.Path == secrets.AllowlistFile {
+			continue
+		}
+
+		entry, err := idx.Entry(change.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s in index: %w", change.Path, err)
+		}
+		content, ok, err := r.readBlob(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read staged %s: %w", change.Path, err)
+		}
+		if !ok {
+			continue
+		}
+
+		oldPath := change.Path
+		if change.OldPath != "" {
+			oldPath = change.OldPath
+		}
+		var previous string
+		if base != nil {
+			if file, err := base.File(oldPath); err == nil {
+				previous, _, err = r.readBlob(file.Hash)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read previous %s: %w", oldPath, err)
+				}
+			}
+		}
+
+		for _, finding := range scanner.ScanFile(change.Path, content) {
+			if previous != "" && strings.Contains(previous, finding.Secret) {
+				continue
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	return allowlist.Filter(findings), nil
+}
+
+// UnstageFiles restores the index entries of paths to HEAD, removing files
+// that HEAD does not have, so that their changes stay in the worktree only
+func (r *RepoManager) UnstageFiles(paths []string) error {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	headTree, err := r.headTree()
+	if err != nil {
+		return err
+	}
+
+	if err := restoreEntries(idx, headTree, paths); err != nil {
+		return err
+	}
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// restoreEntries sets the entries of paths in idx to their versions in
+// tree, removing those that tree, which may be nil, does not have
+func restoreEntries(idx *index.Index, tree *object.Tree, paths []string) error {
+	for _, path := range paths {
+		var file *object.File
+		if tree != nil {
+			file, _ = tree.File(path)
+		}
+		if file == nil {
+			if _, err := idx.Remove(path); err != nil {
+				return fmt.Errorf("failed to unstage %s: %w", path, err)
+			}
+			continue
+		}
+
+		entry, err := idx.Entry(path)
+		if err != nil {
+			return fmt.Errorf("failed to unstage %s: %w", path, err)
+		}
+		entry.Hash = file.Hash
+		entry.Mode = file.Mode
+		entry.Size = uint32(file.Size)
+		// Clear the cached stat data so the worktree file is compared by content
+		entry.ModifiedAt = time.Time{}
+		entry.CreatedAt = time.Time{}
+	}
+	return nil
+}
+
+// headTree returns the tree of HEAD, or nil in a repository without commits
+func (r *RepoManager) headTree() (*object.Tree, error) {
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD tree: %w", err)
+	}
+	return tree, nil
+}
+
+// readBlob returns a blob's content, reporting false for binary and very large blobs
+func (r *RepoManager) readBlob(hash plumbing.Hash) (string, bool, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return "", false, err
+	}
+	if blob.Size > maxScanSize {
+		return "", false, nil
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", false, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", false, err
+	}
+	// Git's own heuristic: a NUL byte in the first 8000 bytes means binary
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "", false, nil
+	}
+	return string(content), true, nil
+}
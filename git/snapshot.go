@@ -0,0 +1,293 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/tejzpr/commitmonk/secrets"
+)
+
+// SnapshotRefPrefix starts the name of every snapshot ref
+const SnapshotRefPrefix = "refs/commitmonk/"
+
+// emptyTree is the hash of git's empty tree, the base of a repository's first snapshot
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// SnapshotRef returns the ref that snapshots of a branch are committed to
+func SnapshotRef(branch string) string {
+	return SnapshotRefPrefix + branch
+}
+
+// Snapshot stages the working tree into a temporary index and commits it to
+// a snapshot ref. HEAD, the index and the checked-out branch are never
+// changed. It offers the same staging and commit operations as RepoManager,
+// with the previous snapshot or HEAD in place of HEAD.
+type Snapshot struct {
+	// Ref is the ref snapshots are committed to
+	Ref string
+
+	repo      *RepoManager
+	indexFile string
+	// previous is the ref's commit, or "" before the first snapshot
+	previous string
+	// head is the HEAD commit, or "" in a repository without commits
+	head string
+	// base is the commit changes are staged relative to: the previous
+	// snapshot, or HEAD if it has moved on since
+	base string
+}
+
+// NewSnapshot prepares a snapshot of the checked-out branch. With autoAdd,
+// all changes outside the exclude patterns are staged into a temporary copy
+// of the index; otherwise only what is already staged is included. It
+// requires the git executable. Close removes the temporary index.
+func (r *RepoManager) NewSnapshot(ctx context.Context, branch string, autoAdd bool, excludePatterns string) (*Snapshot, error) {
+	if branch == "" {
+		return nil, fmt.Errorf("snapshots require a checked-out branch")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("snapshots require the git executable: %w", err)
+	}
+
+	s := &Snapshot{Ref: SnapshotRef(branch), repo: r}
+	var err error
+	if s.previous, err = r.resolveCommit(ctx, s.Ref); err != nil {
+		return nil, err
+	}
+	if s.head, err = r.resolveCommit(ctx, "HEAD"); err != nil {
+		return nil, err
+	}
+	s.base = s.previous
+	if s.head != "" && s.previous != "" {
+		// A user commit since the last snapshot becomes the new base
+		if _, err := r.gitOutput(ctx, nil, "merge-base", "--is-ancestor", s.head, s.previous); err != nil {
+			if !isExitCode(err, 1) {
+				return nil, err
+			}
+			s.base = s.head
+		}
+	} else if s.previous == "" {
+		s.base = s.head
+	}
+
+	if s.indexFile, err = r.tempIndex(ctx); err != nil {
+		return nil, err
+	}
+	if autoAdd {
+		if err := s.stageAll(ctx, excludePatterns); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Close removes the temporary index
+func (s *Snapshot) Close() error {
+	return os.Remove(s.indexFile)
+}
+
+// stageAll stages all changes except those matching exclude patterns
+func (s *Snapshot) stageAll(ctx context.Context, excludePatterns string) error {
+	if _, err := s.git(ctx, "add", "-A", "."); err != nil {
+		return err
+	}
+
+	excludes, err := CompileExcludePatterns(excludePatterns)
+	if err != nil || len(excludes) == 0 {
+		return err
+	}
+	changes, err := s.StagedChanges(ctx)
+	if err != nil {
+		return err
+	}
+	var excluded []string
+	for _, change := range changes {
+		if MatchesExclude(excludes, change.Path) {
+			excluded = append(excluded, change.Path)
+		}
+	}
+	return s.UnstageFiles(excluded)
+}
+
+// HasStagedChanges reports whether the snapshot differs from its base
+func (s *Snapshot) HasStagedChanges() (bool, error) {
+	_, err := s.git(context.Background(), "diff", "--cached", "--quiet", s.baseTree())
+	if isExitCode(err, 1) {
+		return true, nil
+	}
+	return false, err
+}
+
+// StagedChanges lists the files that changed since the base and how
+func (s *Snapshot) StagedChanges(ctx context.Context) ([]FileChange, error) {
+	output, err := s.git(ctx, "diff", "--cached", "--name-status", "-M", "-z", s.baseTree())
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(output), nil
+}
+
+// GetDiff returns the diff of the snapshot against its base
+func (s *Snapshot) GetDiff(ctx context.Context) (string, error) {
+	return s.git(ctx, "diff", "--cached", s.baseTree())
+}
+
+// ScanStaged scans the changes since the base for secrets they introduce,
+// like RepoManager.ScanStaged
+func (s *Snapshot) ScanStaged(ctx context.Context, scanner *secrets.Scanner) ([]secrets.Finding, error) {
+	changes, err := s.StagedChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index: %w", err)
+	}
+	defer file.Close()
+	idx := &index.Index{}
+	if err := index.NewDecoder(file).Decode(idx); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index: %w", err)
+	}
+
+	var base *object.Tree
+	if s.base != "" {
+		commit, err := s.repo.repo.CommitObject(plumbing.NewHash(s.base))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot base: %w", err)
+		}
+		if base, err = commit.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot base tree: %w", err)
+		}
+	}
+	return s.repo.scanIndex(ctx, scanner, changes, idx, base)
+}
+
+// UnstageFiles leaves the changes to paths out of the snapshot
+func (s *Snapshot) UnstageFiles(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	args := []string{"reset", "-q", s.base, "--"}
+	if s.base == "" {
+		args = []string{"rm", "-q", "--cached", "--ignore-unmatch", "--"}
+	}
+	if _, err := s.git(ctx, append(args, paths...)...); err != nil {
+		return fmt.Errorf("failed to unstage files from snapshot: %w", err)
+	}
+	return nil
+}
+
+// ResetIndex does nothing: the repository's index is never changed and the
+// temporary index is discarded by Close
+func (s *Snapshot) ResetIndex() error {
+	return nil
+}
+
+// Commit commits the snapshot to its ref and returns the commit's hash. Its
+// parents are the previous snapshot and, if it has moved on since, HEAD.
+// Authorship and signing work as in RepoManager.Commit.
+func (s *Snapshot) Commit(ctx context.Context, message string, opts CommitOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	hasStagedChanges, err := s.HasStagedChanges()
+	if err != nil {
+		return "", fmt.Errorf("failed to check for staged changes: %w", err)
+	}
+	if !hasStagedChanges {
+		return "", fmt.Errorf("no staged changes to commit")
+	}
+
+	author, committer, err := s.repo.identities(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	signing, err := s.repo.SigningConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// A started commit is not cancelled
+	ctx = context.Background()
+	tree, err := s.git(ctx, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write snapshot tree: %w", err)
+	}
+
+	args := []string{"commit-tree", tree, "-m", addTrailers(message, opts)}
+	if s.previous != "" {
+		args = append(args, "-p", s.previous)
+	}
+	if s.head != "" && s.base == s.head && s.head != s.previous {
+		args = append(args, "-p", s.head)
+	}
+	if signing.Enabled {
+		args = append(args, "-S")
+	}
+	env := identityEnv(author, committer)
+	hash, err := s.repo.gitOutput(ctx, env, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot commit: %w", err)
+	}
+
+	// Only move the ref if no one else has since it was read
+	oldValue := s.previous
+	if oldValue == "" {
+		oldValue = plumbing.ZeroHash.String()
+	}
+	if _, err := s.repo.gitOutput(ctx, nil, "update-ref", "-m", "commitmonk: snapshot", s.Ref, hash, oldValue); err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", s.Ref, err)
+	}
+	s.previous, s.base = hash, hash
+	return hash, nil
+}
+
+// Push pushes the snapshot ref, and nothing else, to the target's remote and
+// mirrors. The target's branch is not used. If only a mirror fails, the
+// error wraps ErrPushIncomplete.
+func (s *Snapshot) Push(ctx context.Context, target PushTarget) error {
+	refSpec := config.RefSpec(s.Ref + ":" + s.Ref)
+	if err := s.repo.pushRefSpec(ctx, target.Remote, refSpec); err != nil {
+		return fmt.Errorf("failed to push %s to %s: %w", s.Ref, target.Remote, err)
+	}
+	return incompletePush(s.repo.pushMirrors(ctx, target, refSpec))
+}
+
+// baseTree returns what changes are compared against
+func (s *Snapshot) baseTree() string {
+	if s.base == "" {
+		return emptyTree
+	}
+	return s.base
+}
+
+// git runs the git executable with the snapshot's index
+func (s *Snapshot) git(ctx context.Context, args ...string) (string, error) {
+	return s.repo.gitOutput(ctx, []string{"GIT_INDEX_FILE=" + s.indexFile}, args...)
+}
+
+// resolveCommit returns the commit a ref points to, or "" if it does not exist
+func (r *RepoManager) resolveCommit(ctx context.Context, ref string) (string, error) {
+	hash, err := r.gitOutput(ctx, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if isExitCode(err, 1) {
+		return "", nil
+	}
+	return hash, err
+}
+
+// isExitCode reports whether err is the git executable exiting with code
+func isExitCode(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
@@ -0,0 +1,193 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// CommitInfo describes a commit considered for squashing
+type CommitInfo struct {
+	Hash      string
+	Message   string
+	Author    Identity
+	Committer Identity
+}
+
+// Subject returns the first line of the commit message
+func (c CommitInfo) Subject() string {
+	subject := strings.TrimSpace(c.Message)
+	if i := strings.IndexByte(subject, '\n'); i >= 0 {
+		subject = strings.TrimSpace(subject[:i])
+	}
+	return subject
+}
+
+// ByBot reports whether a commitmonk run made the commit, according to its
+// run trailer, or commitmonk authored or committed it or is credited as its
+// co-author
+func (c CommitInfo) ByBot() bool {
+	if c.Author == BotIdentity || c.Committer == BotIdentity {
+		return true
+	}
+	for _, line := range strings.Split(c.Message, "\n") {
+		if strings.HasPrefix(line, RunTrailer+":") || line == "Co-authored-by: "+BotIdentity.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// TrailingCommits walks back from HEAD over the commits that match and
+// returns them newest first, together with the parent of the oldest one,
+// which is "" when it is the root commit. Merge commits end the walk.
+func (r *RepoManager) TrailingCommits(match func(CommitInfo) bool) ([]CommitInfo, string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+
+	var commits []CommitInfo
+	for {
+		info := CommitInfo{
+			Hash:      commit.Hash.String(),
+			Message:   commit.Message,
+			Author:    Identity{Name: commit.Author.Name, Email: commit.Author.Email},
+			Committer: Identity{Name: commit.Committer.Name, Email: commit.Committer.Email},
+		}
+		if commit.NumParents() > 1 || !match(info) {
+			return commits, info.Hash, nil
+		}
+		commits = append(commits, info)
+
+		commit, err = commit.Parent(0)
+		if err == object.ErrParentNotFound || err == io.EOF {
+			return commits, "", nil
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read parent of %s: %w", info.Hash, err)
+		}
+	}
+}
+
+// DiffBetween returns the diff from one commit to another; an empty from
+// diffs against the empty tree. It requires the git executable.
+func (r *RepoManager) DiffBetween(ctx context.Context, from, to string) (string, error) {
+	if from == "" {
+		from = emptyTree
+	}
+	return r.gitOutput(ctx, nil, "diff", from, to)
+}
+
+// Squash replaces commits, as returned by TrailingCommits, with one commit
+// of their combined changes on top of base and moves the checked-out branch
+// to it. The working tree and index are not touched. Authorship and signing
+// work as in Commit. It requires the git executable.
+func (r *RepoManager) Squash(ctx context.Context, commits []CommitInfo, base, message string, opts CommitOptions) (string, error) {
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits to squash")
+	}
+	branchRef, err := r.gitOutput(ctx, nil, "symbolic-ref", "--quiet", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("squashing requires a checked-out branch: %w", err)
+	}
+
+	author, committer, err := r.identities(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	signing, err := r.SigningConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tip := commits[0].Hash
+	args := []string{"commit-tree", tip + "^{tree}", "-m", addTrailers(message, opts)}
+	if base != "" {
+		args = append(args, "-p", base)
+	}
+	if signing.Enabled {
+		args = append(args, "-S")
+	}
+	hash, err := r.gitOutput(ctx, identityEnv(author, committer), args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create squashed commit: %w", err)
+	}
+
+	// Only move the branch if no commit was added since it was read
+	reason := fmt.Sprintf("commitmonk: squash %d commits", len(commits))
+	if _, err := r.gitOutput(ctx, nil, "update-ref", "-m", reason, branchRef, hash, tip); err != nil {
+		return "", fmt.Errorf("failed to update %s: %w", branchRef, err)
+	}
+	return hash, nil
+}
+
+// RemoteBranchesContaining lists the remote-tracking branches, such as
+// origin/main, that contain a commit
+func (r *RepoManager) RemoteBranchesContaining(ctx context.Context, commit string) ([]string, error) {
+	output, err := r.gitOutput(ctx, nil, "for-each-ref", "--contains", commit, "--format=%(refname:short)", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// Upstream returns the remote-tracking branch the checked-out branch
+// tracks, e.g. origin/main, and the commit it points to. Both are "" when
+// the branch has no upstream.
+func (r *RepoManager) Upstream(ctx context.Context) (string, string, error) {
+	upstream, err := r.gitOutput(ctx, nil, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		// git exits with 128 both for a missing upstream and a detached HEAD
+		if isExitCode(err, 128) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	hash, err := r.resolveCommit(ctx, "@{upstream}")
+	if err != nil {
+		return "", "", err
+	}
+	return upstream, hash, nil
+}
+
+// ForcePushWithLease pushes the checked-out branch to its upstream,
+// replacing what is there only if the upstream still points at expected
+func (r *RepoManager) ForcePushWithLease(ctx context.Context, expected string) error {
+	branch, err := r.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		return fmt.Errorf("pushing requires a checked-out branch")
+	}
+	remote, err := r.configValue(ctx, "branch."+branch+".remote")
+	if err != nil {
+		return err
+	}
+	merge, err := r.configValue(ctx, "branch."+branch+".merge")
+	if err != nil {
+		return err
+	}
+	if remote == "" || merge == "" {
+		return fmt.Errorf("branch %s has no upstream", branch)
+	}
+
+	lease := fmt.Sprintf("--force-with-lease=%s:%s", merge, expected)
+	refSpec := plumbing.NewBranchReferenceName(branch).String() + ":" + merge
+	if _, err := r.gitOutput(ctx, nil, "push", lease, remote, refSpec); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+var (
+	// ErrDiverged means the remote branch has commits the local branch lacks
+	ErrDiverged = errors.New("the remote branch has commits that are not in the local branch")
+	// ErrSyncConflict means the remote commits could not be integrated
+	// without conflicts, and the attempt was aborted
+	ErrSyncConflict = errors.New("the remote commits conflict with local commits")
+	// ErrSyncUnavailable means syncing needs the git executable, which is not installed
+	ErrSyncUnavailable = errors.New("syncing requires the git executable")
+)
+
+// trackingRef is where FetchBehind stores the remote branch
+func trackingRef(remote, branch string) string {
+	return "refs/remotes/" + remote + "/" + branch
+}
+
+// FetchBehind fetches the target's branch and returns how many of its
+// commits the local branch lacks. A remote without the branch has none. It
+// returns ErrSyncUnavailable without the git executable.
+func (r *RepoManager) FetchBehind(ctx context.Context, target PushTarget) (int, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return 0, ErrSyncUnavailable
+	}
+	remote, branch := target.Remote, target.Branch
+
+	// ls-remote exits with 2 when the branch does not exist on the remote yet
+	remoteRef := "refs/heads/" + branch
+	if _, err := r.gitOutput(ctx, nil, "ls-remote", "--exit-code", remote, remoteRef); err != nil {
+		if isExitCode(err, 2) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to query %s: %w", remote, err)
+	}
+
+	tracking := trackingRef(remote, branch)
+	if _, err := r.gitOutput(ctx, nil, "fetch", "--quiet", "--no-tags", remote, "+"+remoteRef+":"+tracking); err != nil {
+		return 0, fmt.Errorf("failed to fetch from %s: %w", remote, err)
+	}
+
+	count, err := r.gitOutput(ctx, nil, "rev-list", "--count", "HEAD.."+tracking)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(count)
+}
+
+// RebaseOnRemote replays the local commits on top of the target's branch
+// fetched by FetchBehind. Uncommitted changes are stashed for the duration.
+// On a conflict the rebase is aborted, restoring the branch, index and
+// working tree, and ErrSyncConflict is returned. opts selects the committer.
+func (r *RepoManager) RebaseOnRemote(ctx context.Context, target PushTarget, opts CommitOptions) error {
+	author, committer, err := r.identities(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// A sync is not cancelled halfway
+	ctx = context.Background()
+	_, err = r.gitOutput(ctx, identityEnv(author, committer), "rebase", "--quiet", "--autostash", "--no-verify", trackingRef(target.Remote, target.Branch))
+	if err == nil {
+		return nil
+	}
+	// Aborting only succeeds when the rebase stopped partway
+	if _, abortErr := r.gitOutput(ctx, nil, "rebase", "--abort"); abortErr == nil {
+		return fmt.Errorf("%w: rebase onto %s aborted: %v", ErrSyncConflict, target, err)
+	}
+	return fmt.Errorf("failed to rebase onto %s: %w", target, err)
+}
+
+// MergeRemote merges the target's branch fetched by FetchBehind into the
+// local branch. Uncommitted changes are stashed for the duration. On a
+// conflict the merge is aborted, restoring the branch, index and working
+// tree, and ErrSyncConflict is returned. opts selects the merge commit's
+// author.
+func (r *RepoManager) MergeRemote(ctx context.Context, target PushTarget, opts CommitOptions) error {
+	author, committer, err := r.identities(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// A sync is not cancelled halfway
+	ctx = context.Background()
+	// The short name gives the merge commit a readable subject
+	_, err = r.gitOutput(ctx, identityEnv(author, committer), "merge", "--quiet", "--autostash", "--no-verify", "--no-edit", target.String())
+	if err == nil {
+		return nil
+	}
+	// Aborting only succeeds when the merge stopped with conflicts
+	if _, abortErr := r.gitOutput(ctx, nil, "merge", "--abort"); abortErr == nil {
+		return fmt.Errorf("%w: merge of %s aborted: %v", ErrSyncConflict, target, err)
+	}
+	return fmt.Errorf("failed to merge %s: %w", target, err)
+}
+
+// HeadCommit returns the hash of the commit HEAD points to
+func (r *RepoManager) HeadCommit() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	return head.Hash().String(), nil
+}
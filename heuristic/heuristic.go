@@ -0,0 +1,269 @@
+package heuristic
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tejzpr/commitmonk/git"
+)
+
+// maxSubjectLength is the longest message Message produces
+const maxSubjectLength = 72
+
+// languages names the file types worth mentioning in a message, by extension
+var languages = map[string]string{
+	".go":    "Go",
+	".py":    "Python",
+	".js":    "JavaScript",
+	".jsx":   "JavaScript",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".rs":    "Rust",
+	".java":  "Java",
+	".kt":    "Kotlin",
+	".rb":    "Ruby",
+	".php":   "PHP",
+	".c":     "C",
+	".h":     "C",
+	".cpp":   "C++",
+	".cs":    "C#",
+	".swift": "Swift",
+	".sh":    "shell",
+	".sql":   "SQL",
+	".html":  "HTML",
+	".css":   "CSS",
+	".scss":  "CSS",
+	".md":    "Markdown",
+	".json":  "JSON",
+	".yml":   "YAML",
+	".yaml":  "YAML",
+	".toml":  "TOML",
+}
+
+// buildFiles are project and dependency manifests
+var buildFiles = map[string]bool{
+	"Makefile":          true,
+	"Dockerfile":        true,
+	"go.mod":            true,
+	"go.sum":            true,
+	"package.json":      true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"Cargo.toml":        true,
+	"Cargo.lock":        true,
+	"pyproject.toml":    true,
+	"requirements.txt":  true,
+	"pom.xml":           true,
+	"build.gradle":      true,
+}
+
+// Message derives a one-line Conventional Commits message from staged
+// changes without calling a model, e.g. "chore(api): update 3 files in handlers/"
+func Message(changes []git.FileChange) string {
+	if len(changes) == 0 {
+		return "chore: update files"
+	}
+
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = change.Path
+	}
+
+	commitType := commitType(paths)
+	scope, location := scopeAndLocation(paths)
+	header := commitType
+	// A scope repeating the type or naming a hidden directory adds nothing
+	if scope != "" && scope != commitType && !strings.HasPrefix(scope, ".") {
+		header += "(" + scope + ")"
+	}
+
+	verb := verb(changes)
+	object := object(changes)
+	details := []string{
+		// Dropped from the end until the message fits
+		location,
+		kindCounts(changes),
+	}
+
+	for n := len(details); n >= 0; n-- {
+		message := header + ": " + verb + " " + object
+		for _, detail := range details[:n] {
+			if detail != "" {
+				message += " " + detail
+			}
+		}
+		if len(message) <= maxSubjectLength {
+			return message
+		}
+	}
+
+	message := fmt.Sprintf("%s: %s %d files", header, verb, len(changes))
+	if len(message) > maxSubjectLength {
+		message = fmt.Sprintf("%s: %s %d files", commitType, verb, len(changes))
+	}
+	return message
+}
+
+// commitType picks the Conventional Commits type all paths agree on, or chore
+func commitType(paths []string) string {
+	classify := func(p string) string {
+		base := path.Base(p)
+		lower := strings.ToLower(p)
+		switch {
+		case strings.HasPrefix(p, ".github/workflows/") || base == ".gitlab-ci.yml" || strings.HasPrefix(p, ".circleci/"):
+			return "ci"
+		case strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+			strings.HasPrefix(base, "test_") || hasDir(p, "test") || hasDir(p, "tests"):
+			return "test"
+		case strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".rst") || hasDir(p, "docs") ||
+			strings.HasPrefix(strings.ToUpper(base), "README") || strings.HasPrefix(strings.ToUpper(base), "CHANGELOG"):
+			return "docs"
+		case buildFiles[base]:
+			return "build"
+		}
+		return "chore"
+	}
+
+	commitType := classify(paths[0])
+	for _, p := range paths[1:] {
+		if classify(p) != commitType {
+			return "chore"
+		}
+	}
+	return commitType
+}
+
+// hasDir reports whether p lies inside a directory with the given name
+func hasDir(p, dir string) bool {
+	return strings.HasPrefix(p, dir+"/") || strings.Contains(p, "/"+dir+"/")
+}
+
+// scopeAndLocation returns the top-level directory shared by all paths as a
+// scope, and the rest of their common directory as a location such as
+// "in handlers/". Paths in up to two different top-level directories are
+// named in the location instead.
+func scopeAndLocation(paths []string) (string, string) {
+	common := path.Dir(paths[0])
+	for _, p := range paths[1:] {
+		common = commonDir(common, path.Dir(p))
+	}
+
+	if common != "." {
+		parts := strings.SplitN(common, "/", 2)
+		// The object already names a single file
+		if len(parts) == 1 || len(paths) == 1 {
+			return parts[0], ""
+		}
+		return parts[0], "in " + parts[1] + "/"
+	}
+
+	if len(paths) == 1 {
+		return "", ""
+	}
+
+	topDirs := make(map[string]bool)
+	for _, p := range paths {
+		if i := strings.IndexByte(p, '/'); i >= 0 {
+			topDirs[p[:i]+"/"] = true
+		} else {
+			topDirs["the root"] = true
+		}
+	}
+	if len(topDirs) > 2 {
+		return "", ""
+	}
+
+	dirs := make([]string, 0, len(topDirs))
+	for dir := range topDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return "", "in " + strings.Join(dirs, " and ")
+}
+
+// commonDir returns the longest directory containing both a and b
+func commonDir(a, b string) string {
+	aParts, bParts := strings.Split(a, "/"), strings.Split(b, "/")
+	var shared []string
+	for i := 0; i < len(aParts) && i < len(bParts) && aParts[i] == bParts[i]; i++ {
+		shared = append(shared, aParts[i])
+	}
+	if len(shared) == 0 || shared[0] == "." {
+		return "."
+	}
+	return strings.Join(shared, "/")
+}
+
+// verb describes what happened to the files as a whole
+func verb(changes []git.FileChange) string {
+	kind := changes[0].Kind
+	for _, change := range changes[1:] {
+		if change.Kind != kind {
+			return "update"
+		}
+	}
+
+	switch kind {
+	case git.ChangeAdded:
+		return "add"
+	case git.ChangeDeleted:
+		return "remove"
+	case git.ChangeRenamed:
+		return "rename"
+	}
+	return "update"
+}
+
+// object names the single changed file, or counts the files and names their dominant type
+func object(changes []git.FileChange) string {
+	if len(changes) == 1 {
+		change := changes[0]
+		if change.Kind == git.ChangeRenamed && change.OldPath != "" {
+			return path.Base(change.OldPath) + " to " + path.Base(change.Path)
+		}
+		return path.Base(change.Path)
+	}
+
+	counts := make(map[string]int)
+	for _, change := range changes {
+		if language, ok := languages[strings.ToLower(path.Ext(change.Path))]; ok {
+			counts[language]++
+		}
+	}
+
+	dominant, most := "", 0
+	for language, count := range counts {
+		if count > most || count == most && language < dominant {
+			dominant, most = language, count
+		}
+	}
+
+	switch {
+	case most == len(changes):
+		return fmt.Sprintf("%d %s files", len(changes), dominant)
+	case most*2 > len(changes):
+		return fmt.Sprintf("%d files, mostly %s", len(changes), dominant)
+	}
+	return fmt.Sprintf("%d files", len(changes))
+}
+
+// kindCounts summarizes a mix of added, deleted and renamed files, e.g. "(2 added, 1 deleted)"
+func kindCounts(changes []git.FileChange) string {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	if len(counts) < 2 {
+		return ""
+	}
+
+	var parts []string
+	for _, kind := range []string{git.ChangeAdded, git.ChangeModified, git.ChangeDeleted, git.ChangeRenamed} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// anthropicBaseURL is the public Anthropic API endpoint
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	// anthropicVersion is the Messages API version we speak
+	anthropicVersion = "2023-06-01"
+)
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// anthropicRequest represents the request structure for the Messages API
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens"`
+}
+
+// anthropicResponse represents the response structure from the Messages API
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// Complete sends the request to the Messages API
+func (p *anthropicProvider) Complete(ctx context.Context, completion CompletionRequest) (string, error) {
+	msgReq := anthropicRequest{
+		Model:  completion.Model,
+		System: completion.System,
+		Messages: []Message{
+			{Role: "user", Content: completion.Prompt},
+		},
+		MaxTokens: completion.MaxTokens,
+	}
+
+	reqBody, err := json.Marshal(msgReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/messages", strings.TrimSuffix(p.baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", completion.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		// The message is optional; the status code alone classifies the error
+		_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+		return "", newAPIError(resp, errorResponse.Error.Message)
+	}
+
+	var msgResp anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&msgResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	// Concatenate the text blocks of the reply
+	var text strings.Builder
+	for _, block := range msgResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+
+	return text.String(), nil
+}
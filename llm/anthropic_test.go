@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicComplete(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("request = %s %s, want POST /v1/messages", r.Method, r.URL.Path)
+		}
+		if key := r.Header.Get("x-api-key"); key != "test-key" {
+			t.Errorf("x-api-key = %q, want %q", key, "test-key")
+		}
+		if version := r.Header.Get("anthropic-version"); version != anthropicVersion {
+			t.Errorf("anthropic-version = %q, want %q", version, anthropicVersion)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Authorization = %q, want none", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "msg_1",
+			"type": "message",
+			"content": [
+				{"type": "text", "text": "feat: add "},
+				{"type": "tool_use", "text": "ignored"},
+				{"type": "text", "text": "login"}
+			],
+			"stop_reason": "end_turn"
+		}`))
+	}))
+	defer server.Close()
+
+	provider := &anthropicProvider{baseURL: server.URL + "/v1/", client: server.Client()}
+	message, err := provider.Complete(context.Background(), CompletionRequest{
+		APIKey:    "test-key",
+		Model:     "claude-test",
+		System:    "You write commit messages.",
+		Prompt:    "diff --git a/login.go b/login.go",
+		MaxTokens: 100,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if message != "feat: add login" {
+		t.Errorf("Complete() = %q, want %q", message, "feat: add login")
+	}
+
+	if got.Model != "claude-test" || got.MaxTokens != 100 {
+		t.Errorf("model, max_tokens = %q, %d, want %q, 100", got.Model, got.MaxTokens, "claude-test")
+	}
+	if got.System != "You write commit messages." {
+		t.Errorf("system = %q, want the system prompt", got.System)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content != "diff --git a/login.go b/login.go" {
+		t.Errorf("messages = %+v, want only the user prompt", got.Messages)
+	}
+}
+
+func TestAnthropicCompleteWithoutText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content": []}`))
+	}))
+	defer server.Close()
+
+	provider := &anthropicProvider{baseURL: server.URL, client: server.Client()}
+	if _, err := provider.Complete(context.Background(), CompletionRequest{}); err == nil {
+		t.Error("Complete() error = nil, want an error for an empty reply")
+	}
+}
+
+func TestAnthropicCompleteErrors(t *testing.T) {
+	testCompleteErrors(t, func(baseURL string, client *http.Client) Provider {
+		return &anthropicProvider{baseURL: baseURL, client: client}
+	}, `{"type": "error", "error": {"type": "invalid_request_error", "message": %q}}`)
+}
@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// lockfiles are regenerated by package managers and say little about a change
+var lockfiles = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"go.sum":              true,
+	"Cargo.lock":          true,
+	"poetry.lock":         true,
+	"Pipfile.lock":        true,
+	"Gemfile.lock":        true,
+	"composer.lock":       true,
+	"flake.lock":          true,
+}
+
+// generatedDirs hold vendored or installed dependencies
+var generatedDirs = []string{"vendor/", "node_modules/"}
+
+// generatedSuffixes mark minified or code-generated files
+var generatedSuffixes = []string{".min.js", ".min.css", ".js.map", ".pb.go", "_generated.go"}
+
+// fileDiff is the part of a unified diff that touches a single file
+type fileDiff struct {
+	Path string
+	Body string
+}
+
+// EstimateTokens approximates the number of tokens in s. Most tokenizers
+// average about four characters of English or code per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// splitDiff breaks a unified diff into per-file sections
+func splitDiff(diff string) []fileDiff {
+	var files []fileDiff
+	for i, section := range strings.Split(diff, "\ndiff --git ") {
+		// Split drops the header prefix from every section but the first
+		if i > 0 {
+			section = "diff --git " + section
+		}
+		if strings.TrimSpace(section) == "" {
+			continue
+		}
+		files = append(files, fileDiff{Path: diffPath(section), Body: section})
+	}
+	return files
+}
+
+// diffPath extracts the new path from a "diff --git a/x b/x" header
+func diffPath(section string) string {
+	header := section
+	if i := strings.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+3:]
+	}
+	return strings.TrimPrefix(header, "diff --git ")
+}
+
+// noiseKind reports why a file's diff is not worth sending to the model, or "" if it is
+func noiseKind(file fileDiff) string {
+	if strings.Contains(file.Body, "\nGIT binary patch\n") || strings.Contains(file.Body, "\nBinary files ") {
+		return "binary file"
+	}
+	if lockfiles[path.Base(file.Path)] {
+		return "lockfile"
+	}
+	for _, dir := range generatedDirs {
+		if strings.HasPrefix(file.Path, dir) || strings.Contains(file.Path, "/"+dir) {
+			return "vendored dependency"
+		}
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(file.Path, suffix) {
+			return "generated file"
+		}
+	}
+	if strings.Contains(file.Body, "Code generated") && strings.Contains(file.Body, "DO NOT EDIT") ||
+		strings.Contains(file.Body, "@generated") {
+		return "generated file"
+	}
+	return ""
+}
+
+// condense replaces the body of a noisy file with a one-line description
+func condense(file fileDiff, kind string) fileDiff {
+	added, removed := 0, 0
+	for _, line := range strings.Split(file.Body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+
+	body := fmt.Sprintf("diff --git a/%s b/%s\n[%s changed: %d lines added, %d removed; contents omitted]\n",
+		file.Path, file.Path, kind, added, removed)
+	return fileDiff{Path: file.Path, Body: body}
+}
+
+// truncate cuts s to roughly the given number of tokens at a line boundary
+func truncate(s string, tokens int) string {
+	limit := tokens * 4
+	if len(s) <= limit {
+		return s
+	}
+
+	cut := s[:limit]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	omitted := strings.Count(s[len(cut):], "\n")
+	return fmt.Sprintf("%s[... %d more lines truncated]\n", cut, omitted)
+}
+
+// joinDiffs concatenates file diffs back into a single diff
+func joinDiffs(files []fileDiff) string {
+	var b strings.Builder
+	for _, file := range files {
+		b.WriteString(file.Body)
+		if !strings.HasSuffix(file.Body, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// chunkFiles groups consecutive files into chunks of at most budget tokens,
+// truncating any single file that does not fit on its own
+func chunkFiles(files []fileDiff, budget int) [][]fileDiff {
+	var (
+		chunks [][]fileDiff
+		chunk  []fileDiff
+		size   int
+	)
+	for _, file := range files {
+		if EstimateTokens(file.Body) > budget {
+			file.Body = truncate(file.Body, budget)
+		}
+		tokens := EstimateTokens(file.Body)
+		if len(chunk) > 0 && size+tokens > budget {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, file)
+		size += tokens
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// condenseDiff returns diff unchanged if it fits the budget, and otherwise
+// with lockfiles, binary and generated files reduced to a one-line note
+func condenseDiff(diff string, budget int) string {
+	if EstimateTokens(diff) <= budget {
+		return diff
+	}
+
+	files := splitDiff(diff)
+	for i, file := range files {
+		if kind := noiseKind(file); kind != "" {
+			files[i] = condense(file, kind)
+		}
+	}
+	return joinDiffs(files)
+}
+
+// summaryInstructions asks the model to describe one chunk of a large diff
+const summaryInstructions = "Summarize the changes in the following Git diff for someone writing a commit message. " +
+	"Write one short line per file in the form `path: what changed`. Respond with ONLY those lines."
+
+// summaryTokensPerFile bounds the reply length of a chunk summary
+const summaryTokensPerFile = 60
+
+// prepareDiff fits a diff into the prompt budget. Lockfiles, binary and
+// generated files are reduced to a one-line note first. If the diff is still
+// too large, each chunk of files is summarized by the model (map) and the
+// summaries are returned in place of the diff (reduce happens in the final
+// prompt). The second return value reports whether summaries were returned.
+func (c *Client) prepareDiff(ctx context.Context, req CompletionRequest, diff string, budget int) (string, bool, error) {
+	condensed := condenseDiff(diff, budget)
+	if EstimateTokens(condensed) <= budget {
+		return condensed, false, nil
+	}
+	files := splitDiff(condensed)
+
+	// Leave room in each summary request for its instructions
+	chunkBudget := budget - EstimateTokens(summaryInstructions) - 50
+	var summaries []string
+	for _, chunk := range chunkFiles(files, chunkBudget) {
+		maxTokens := summaryTokensPerFile * len(chunk)
+		if maxTokens > 1000 {
+			maxTokens = 1000
+		}
+
+		summary, err := c.complete(ctx, CompletionRequest{
+			APIKey:    req.APIKey,
+			Model:     req.Model,
+			System:    "You summarize code changes.",
+			Prompt:    fmt.Sprintf("%s\n\nDiff:\n%s", summaryInstructions, joinDiffs(chunk)),
+			MaxTokens: maxTokens,
+		})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to summarize diff: %w", err)
+		}
+		summaries = append(summaries, strings.TrimSpace(summary))
+	}
+
+	return truncate(strings.Join(summaries, "\n")+"\n", budget), true, nil
+}
@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of API failure. Use errors.Is to test an error returned by the client.
+var (
+	// ErrRateLimited means the provider rejected the request with 429
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAuth means the API key was missing, invalid or lacks access
+	ErrAuth = errors.New("authentication failed")
+	// ErrContextLength means the prompt exceeded the model's context window
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrServer means the provider failed with a 5xx status
+	ErrServer = errors.New("server error")
+	// ErrUnavailable means the provider could not be reached or did not answer in time
+	ErrUnavailable = errors.New("provider unavailable")
+	// ErrRequest means the provider rejected the request for another reason
+	ErrRequest = errors.New("request rejected")
+)
+
+// contextLengthHints appear in provider error messages about oversized prompts
+var contextLengthHints = []string{
+	"context length", "context_length", "context window", "maximum context",
+	"too many tokens", "prompt is too long",
+}
+
+// APIError describes a failed LLM request
+type APIError struct {
+	// Kind is one of the Err* values above
+	Kind       error
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay the provider asked for, if any
+	RetryAfter time.Duration
+	// Err is the underlying transport error, if any
+	Err error
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+	case e.Message != "":
+		return fmt.Sprintf("API error (%v, status %d): %s", e.Kind, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API error (%v): status %d", e.Kind, e.StatusCode)
+}
+
+// Is lets errors.Is match the error's kind
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying transport error, if any
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether retrying the request later may succeed
+func (e *APIError) Temporary() bool {
+	return e.Kind == ErrRateLimited || e.Kind == ErrServer || e.Kind == ErrUnavailable
+}
+
+// IsTemporary reports whether err is an API failure that may go away on its
+// own. When several endpoints failed, it is enough for one of them.
+func IsTemporary(err error) bool {
+	var chainErr *ChainError
+	if errors.As(err, &chainErr) {
+		return chainErr.Temporary()
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Temporary()
+}
+
+// ChainError reports that every endpoint in the fallback chain failed
+type ChainError struct {
+	// Endpoints and Errors are parallel: Errors[i] is why Endpoints[i] failed
+	Endpoints []string
+	Errors    []error
+}
+
+func (e *ChainError) Error() string {
+	failures := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		failures[i] = fmt.Sprintf("%s: %v", e.Endpoints[i], err)
+	}
+	return "all LLM endpoints failed: " + strings.Join(failures, "; ")
+}
+
+// Temporary reports whether retrying later may succeed on any endpoint
+func (e *ChainError) Temporary() bool {
+	for _, err := range e.Errors {
+		if IsTemporary(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAPIError classifies a non-200 response with the message the provider sent
+func newAPIError(resp *http.Response, message string) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRateLimited
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		apiErr.Kind = ErrAuth
+	case resp.StatusCode == http.StatusRequestEntityTooLarge || containsAny(lower, contextLengthHints):
+		apiErr.Kind = ErrContextLength
+	case resp.StatusCode >= 500:
+		apiErr.Kind = ErrServer
+	default:
+		apiErr.Kind = ErrRequest
+	}
+	return apiErr
+}
+
+// transportError wraps a failure to get a response at all
+func transportError(err error) error {
+	// Cancellation by the caller is not a provider problem
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	return &APIError{Kind: ErrUnavailable, Err: err}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// baseBackoff is the delay before the first retry
+	baseBackoff = time.Second
+	// maxBackoff caps the delay between retries, including Retry-After
+	maxBackoff = time.Minute
+)
+
+// backoff returns how long to wait before retry number attempt (0-based):
+// exponential with full jitter, or the provider's Retry-After if it is longer
+func backoff(attempt int, err error) time.Duration {
+	limit := baseBackoff << uint(attempt)
+	if limit > maxBackoff || limit <= 0 {
+		limit = maxBackoff
+	}
+	delay := time.Duration(rand.Int63n(int64(limit))) + time.Millisecond
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		delay = apiErr.RetryAfter
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// complete sends a request through the provider, retrying temporary
+// failures with backoff up to the configured number of times
+func (c *Client) complete(ctx context.Context, req CompletionRequest) (string, error) {
+	for attempt := 0; ; attempt++ {
+		reply, err := c.provider.Complete(ctx, req)
+		if err == nil || !IsTemporary(err) || attempt >= c.keyConfig.MaxRetries {
+			return reply, err
+		}
+
+		delay := backoff(attempt, err)
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -1,130 +1,373 @@
 package llm
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/tejzpr/commitmonk/config"
+	"github.com/tejzpr/commitmonk/db"
+	"github.com/tejzpr/commitmonk/logger"
 )
 
+// Provider names accepted in the provider key of the [llm] config section
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// Provider sends a completion request to a specific LLM API
+type Provider interface {
+	// Complete returns the model's reply to the request
+	Complete(ctx context.Context, req CompletionRequest) (string, error)
+}
+
+// CompletionRequest is a provider-independent single-turn request
+type CompletionRequest struct {
+	APIKey    string
+	Model     string
+	System    string
+	Prompt    string
+	MaxTokens int
+}
+
 // Client handles interactions with the LLM API
 type Client struct {
+	// Name identifies the endpoint in the run history
+	Name    string
 	BaseURL string
 	APIKey  string
 	Model   string
+
+	provider Provider
+	// fallbacks are the configured endpoints tried in order when this one fails
+	fallbacks []*Client
+	// keyConfig resolves APIKey on first use when it is kept outside the config file
+	keyConfig config.LLMConfig
+	keyMu     sync.Mutex
 }
 
-// NewClient creates a new LLM client from configuration
+// NewClient creates a new LLM client from configuration, falling back to
+// the configured endpoints in order
 func NewClient(cfg config.LLMConfig) *Client {
+	client := newEndpointClient(config.PrimaryEndpoint, cfg)
+	for _, endpoint := range cfg.Endpoints {
+		client.fallbacks = append(client.fallbacks, newEndpointClient(endpoint.Name, cfg.ForEndpoint(endpoint)))
+	}
+	return client
+}
+
+// newEndpointClient creates a client for a single endpoint
+func newEndpointClient(name string, cfg config.LLMConfig) *Client {
+	baseURL := cfg.BaseURL
+	if baseURL == "" || baseURL == config.DefaultConfig().LLM.BaseURL {
+		baseURL = defaultBaseURL(cfg.Provider)
+	}
+
 	return &Client{
-		BaseURL: cfg.BaseURL,
-		APIKey:  cfg.APIKey,
-		Model:   cfg.Model,
+		Name:      name,
+		BaseURL:   baseURL,
+		APIKey:    cfg.APIKey,
+		Model:     cfg.Model,
+		provider:  newProvider(cfg.Provider, baseURL, &http.Client{Timeout: cfg.RequestTimeout()}),
+		keyConfig: cfg,
+	}
+}
+
+// newProvider returns the implementation for a provider name, defaulting to OpenAI
+func newProvider(name, baseURL string, client *http.Client) Provider {
+	switch name {
+	case ProviderAnthropic:
+		return &anthropicProvider{baseURL: baseURL, client: client}
+	case ProviderOllama:
+		return &ollamaProvider{baseURL: baseURL, client: client}
+	default:
+		return &openAIProvider{baseURL: baseURL, client: client}
 	}
 }
 
-// HasCredentials checks if the client has valid credentials
+// defaultBaseURL returns the public endpoint of a provider
+func defaultBaseURL(name string) string {
+	switch name {
+	case ProviderAnthropic:
+		return anthropicBaseURL
+	case ProviderOllama:
+		return ollamaBaseURL
+	default:
+		return config.DefaultConfig().LLM.BaseURL
+	}
+}
+
+// HasCredentials checks if any endpoint has valid credentials. Providers
+// that need no API key always have them.
 func (c *Client) HasCredentials() bool {
-	return c.APIKey != ""
-}
-
-// Message represents a chat message in the API request
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// ChatRequest represents the request structure for chat models
-type ChatRequest struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	MaxTokens int       `json:"max_tokens,omitempty"`
-}
-
-// ChatResponse represents the response structure from chat models
-type ChatResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Choices []struct {
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-}
-
-// GenerateCommitMessage creates a commit message for the given diff
-func (c *Client) GenerateCommitMessage(diff string) (string, error) {
-	if !c.HasCredentials() {
-		return "", fmt.Errorf("LLM API credentials not configured")
-	}
-
-	// Create prompt for the LLM
-	prompt := fmt.Sprintf("You are a Git commit message generator. Your task is to write a clear, "+
-		"concise commit message in the conventional commit format (type: description) based on the "+
-		"following Git diff. Focus only on the most important changes, and keep the message under 72 characters. "+
-		"Respond with ONLY the commit message, nothing else, do not add any other prefix or suffix.\n\nDiff:\n%s", diff)
-
-	// Prepare the request
-	chatReq := ChatRequest{
-		Model: c.Model,
-		Messages: []Message{
-			{Role: "system", Content: "You generate concise git commit messages in conventional format."},
-			{Role: "user", Content: prompt},
-		},
-		MaxTokens: 100,
+	for _, endpoint := range c.chain() {
+		if endpoint.hasCredentials() {
+			return true
+		}
 	}
+	return false
+}
 
-	reqBody, err := json.Marshal(chatReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request body: %w", err)
+// hasCredentials checks this endpoint alone
+func (c *Client) hasCredentials() bool {
+	return c.APIKey != "" || c.keyConfig.IsConfigured()
+}
+
+// chain returns the endpoints in the order they are tried
+func (c *Client) chain() []*Client {
+	return append([]*Client{c}, c.fallbacks...)
+}
+
+// apiKey returns the API key, resolving it from its secret store the first
+// time it is needed. Failed lookups are retried on the next call. Keyless
+// providers get an empty key unless one is configured.
+func (c *Client) apiKey(ctx context.Context) (string, error) {
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+
+	if c.APIKey != "" {
+		return c.APIKey, nil
+	}
+	if !c.keyConfig.HasAPIKey() && !c.keyConfig.RequiresAPIKey() {
+		return "", nil
 	}
 
-	// Make HTTP request
-	endpoint := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(c.BaseURL, "/"))
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(reqBody))
+	key, err := c.keyConfig.ResolveAPIKey(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to resolve API key: %w", err)
+	}
+	c.APIKey = key
+	return key, nil
+}
+
+// Options adjusts a single commit message generation
+type Options struct {
+	// Model overrides the configured model when set
+	Model string
+	// Instructions replaces the default instructions that precede the diff when set
+	Instructions string
+	// TemplateFile overrides the configured prompt template when set
+	TemplateFile string
+	// Format overrides the configured message format (FormatOneline or FormatFull) when set
+	Format string
+	// Style overrides the configured validation style (StyleConventional or StyleNone) when set
+	Style string
+
+	// Context made available to the prompt template
+	Repo          string
+	Branch        string
+	RecentCommits []string
+	Squashed      []string
+	Task          db.Task
+}
+
+// defaultInstructions tells the model what kind of commit message to write
+const defaultInstructions = "You are a Git commit message generator. Your task is to write a clear, " +
+	"concise commit message in the conventional commit format (type: description) based on the " +
+	"following Git diff. Focus only on the most important changes, and keep the message under 72 characters. " +
+	"Respond with ONLY the commit message, nothing else, do not add any other prefix or suffix."
+
+// systemPrompt is sent as the system message of every commit message request
+const systemPrompt = "You generate concise git commit messages in conventional format."
+
+// minDiffBudget is the fewest tokens a diff is given, however long the instructions
+const minDiffBudget = 200
+
+// GenerateCommitMessage creates a commit message for the given diff and
+// returns it with the name of the endpoint that wrote it. Endpoints are
+// tried in order until one succeeds; when all fail, the error of a single
+// endpoint is returned as is and errors of several as a *ChainError.
+func (c *Client) GenerateCommitMessage(ctx context.Context, diff string, opts Options) (string, string, error) {
+	chainErr := &ChainError{}
+	for i, endpoint := range c.chain() {
+		if !endpoint.hasCredentials() {
+			continue
+		}
+
+		endpointOpts := opts
+		if i > 0 {
+			// A repository's model override names a model of the primary endpoint
+			endpointOpts.Model = ""
+		}
+
+		message, err := endpoint.generate(ctx, diff, endpointOpts)
+		if err == nil {
+			return message, endpoint.Name, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", err
+		}
+		if i < len(c.fallbacks) {
+			logger.Printf("LLM endpoint %s failed, trying the next one: %v", endpoint.Name, err)
+		}
+		chainErr.Endpoints = append(chainErr.Endpoints, endpoint.Name)
+		chainErr.Errors = append(chainErr.Errors, err)
+	}
+
+	switch len(chainErr.Errors) {
+	case 0:
+		return "", "", fmt.Errorf("LLM API credentials not configured")
+	case 1:
+		return "", "", chainErr.Errors[0]
 	}
+	return "", "", chainErr
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+// generate creates a commit message using this endpoint alone. Diffs
+// larger than the model's token budget are summarized per file first.
+// Replies that break the configured style are sent back to the model with
+// the violations; a *ValidationError is returned once the repair attempts
+// are used up. Temporary API failures are retried with backoff and then
+// returned as an *APIError.
+func (c *Client) generate(ctx context.Context, diff string, opts Options) (string, error) {
+	apiKey, err := c.apiKey(ctx)
+	if err != nil {
+		return "", err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	req, _, err := c.buildRequest(ctx, apiKey, diff, opts, true)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
+	// Ask the model to repair replies that break the message rules
+	rules := c.rules(opts)
+	prompt := req.Prompt
+	for attempt := 0; ; attempt++ {
+		reply, err := c.complete(ctx, req)
+		if err != nil {
+			return "", err
+		}
+
+		message, err := formatMessage(reply, c.format(opts))
+		if err != nil {
+			return "", err
+		}
+
+		violations := Validate(message, rules)
+		if len(violations) == 0 {
+			return message, nil
+		}
+		if attempt >= c.keyConfig.RepairAttempts {
+			return "", &ValidationError{Message: message, Violations: violations}
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil {
-			return "", fmt.Errorf("API error: %s", errorResponse.Error.Message)
+		req.Prompt = repairPrompt(prompt, reply, violations)
+	}
+}
+
+// rules returns the validation rules for a generation
+func (c *Client) rules(opts Options) Rules {
+	rules := Rules{
+		Style:            c.keyConfig.Style,
+		MaxSubjectLength: maxSubjectLength,
+	}
+	if opts.Style != "" {
+		rules.Style = opts.Style
+	}
+	for _, commitType := range strings.Split(c.keyConfig.CommitTypes, ",") {
+		if commitType = strings.TrimSpace(commitType); commitType != "" {
+			rules.Types = append(rules.Types, commitType)
 		}
-		return "", fmt.Errorf("API returned non-200 status code: %d", resp.StatusCode)
 	}
+	return rules
+}
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+// format returns the message format for a generation
+func (c *Client) format(opts Options) string {
+	if opts.Format != "" {
+		return opts.Format
 	}
+	if c.keyConfig.MessageFormat != "" {
+		return c.keyConfig.MessageFormat
+	}
+	return FormatOneline
+}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from LLM")
+// RenderPrompt returns the prompt GenerateCommitMessage would send for diff
+// without calling the API. The second return value reports whether the diff
+// exceeds the token budget, in which case it would be summarized per file
+// first and the rendered prompt shows the condensed diff instead.
+func (c *Client) RenderPrompt(ctx context.Context, diff string, opts Options) (string, bool, error) {
+	req, overBudget, err := c.buildRequest(ctx, "", diff, opts, false)
+	if err != nil {
+		return "", false, err
 	}
+	return req.Prompt, overBudget, nil
+}
 
-	// Trim any leading/trailing whitespace and quotes
-	message := strings.TrimSpace(chatResp.Choices[0].Message.Content)
-	message = strings.Trim(message, `"'`)
+// buildRequest renders the prompt template around a diff fitted to the
+// model's token budget. Without summarize, an oversized diff is only
+// condensed and the second return value reports that it is over budget.
+func (c *Client) buildRequest(ctx context.Context, apiKey, diff string, opts Options, summarize bool) (CompletionRequest, bool, error) {
+	model := c.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
 
-	return message, nil
+	tmpl, err := c.promptTemplate(opts)
+	if err != nil {
+		return CompletionRequest{}, false, err
+	}
+
+	format := c.format(opts)
+	data := PromptData{
+		Instructions:  defaultInstructions,
+		Format:        format,
+		Repo:          opts.Repo,
+		Branch:        opts.Branch,
+		RecentCommits: opts.RecentCommits,
+		Squashed:      opts.Squashed,
+		Task:          opts.Task,
+	}
+	if format == FormatFull {
+		data.Instructions = fullInstructions
+	}
+	if opts.Instructions != "" {
+		data.Instructions = opts.Instructions
+	}
+	for _, file := range splitDiff(diff) {
+		data.Files = append(data.Files, file.Path)
+	}
+
+	req := CompletionRequest{
+		APIKey:    apiKey,
+		Model:     model,
+		System:    systemPrompt,
+		MaxTokens: 100,
+	}
+	if format == FormatFull {
+		// Room for a body
+		req.MaxTokens = 500
+	}
+
+	// Fit the diff into what remains of the model's budget after the rest of the prompt
+	overhead, err := renderPrompt(tmpl, data)
+	if err != nil {
+		return CompletionRequest{}, false, err
+	}
+	budget := c.keyConfig.TokenBudgetFor(model) - EstimateTokens(systemPrompt+overhead)
+	if budget < minDiffBudget {
+		budget = minDiffBudget
+	}
+
+	var overBudget bool
+	if summarize {
+		data.Diff, data.Summarized, err = c.prepareDiff(ctx, req, diff, budget)
+		if err != nil {
+			return CompletionRequest{}, false, err
+		}
+	} else {
+		data.Diff = condenseDiff(diff, budget)
+		overBudget = EstimateTokens(data.Diff) > budget
+	}
+
+	req.Prompt, err = renderPrompt(tmpl, data)
+	if err != nil {
+		return CompletionRequest{}, false, err
+	}
+	return req, overBudget, nil
 }
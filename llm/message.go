@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Commit message formats accepted in the message_format key of the [llm] config section
+const (
+	// FormatOneline produces a single subject line
+	FormatOneline = "oneline"
+	// FormatFull produces a subject, a blank line and a wrapped body
+	FormatFull = "full"
+)
+
+const (
+	// maxSubjectLength is the longest subject line kept intact
+	maxSubjectLength = 72
+	// bodyWidth is the column the body is wrapped at
+	bodyWidth = 72
+)
+
+// fullInstructions asks for a subject and a body when the full format is selected
+const fullInstructions = "You are a Git commit message generator. Write a commit message for the following Git diff. " +
+	"The first line is a subject in the conventional commit format (type: description) of at most 72 characters. " +
+	"Then write a blank line, followed by a body of bullet points starting with \"- \", one per area of the code " +
+	"that was touched, explaining what changed and why. Wrap the body at 72 characters. " +
+	"Respond with ONLY the commit message, nothing else, do not add any other prefix or suffix."
+
+// bulletPrefixes are the list markers models commonly use
+var bulletPrefixes = []string{"- ", "* ", "• "}
+
+// formatMessage cleans up a model reply. One-line messages only lose
+// surrounding whitespace and quotes; full messages are split into a subject
+// of at most maxSubjectLength characters and a body re-wrapped at bodyWidth.
+func formatMessage(reply string, format string) (string, error) {
+	message := strings.TrimSpace(reply)
+	message = strings.Trim(message, `"'`)
+	if format != FormatFull {
+		return message, nil
+	}
+
+	message = stripCodeFence(message)
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+
+	// The subject is the first non-empty line
+	var subject string
+	for len(lines) > 0 && subject == "" {
+		subject = strings.TrimSpace(lines[0])
+		lines = lines[1:]
+	}
+	if subject == "" {
+		return "", fmt.Errorf("empty commit message from LLM")
+	}
+	subject = shortenSubject(subject)
+
+	body := wrapBody(lines, bodyWidth)
+	if body == "" {
+		return subject, nil
+	}
+	return subject + "\n\n" + body, nil
+}
+
+// stripCodeFence removes a ``` fence some models wrap their reply in
+func stripCodeFence(message string) string {
+	if !strings.HasPrefix(message, "```") {
+		return message
+	}
+	message = strings.TrimPrefix(message, "```")
+	// Drop a language tag on the opening fence
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[i+1:]
+	}
+	message = strings.TrimSuffix(strings.TrimSpace(message), "```")
+	return strings.TrimSpace(message)
+}
+
+// shortenSubject cuts a subject longer than maxSubjectLength at a word boundary
+func shortenSubject(subject string) string {
+	subject = strings.TrimSuffix(subject, ".")
+	if len(subject) <= maxSubjectLength {
+		return subject
+	}
+
+	// Back up to a rune boundary so a multi-byte character is not split
+	end := maxSubjectLength
+	for end > 0 && !utf8.RuneStart(subject[end]) {
+		end--
+	}
+	cut := subject[:end]
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-")
+}
+
+// wrapBody re-wraps body lines at width. Blank lines separate paragraphs,
+// bullet points get a hanging indent, and other lines are joined to the
+// paragraph or bullet they continue.
+func wrapBody(lines []string, width int) string {
+	var (
+		blocks  []string
+		current []string
+		bullet  bool
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		text := strings.Join(current, " ")
+		if bullet {
+			blocks = append(blocks, wrapText(text, width, "- ", "  "))
+		} else {
+			blocks = append(blocks, wrapText(text, width, "", ""))
+		}
+		current = nil
+	}
+
+	paragraphBreak := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			paragraphBreak = true
+			continue
+		}
+
+		if text, ok := trimBullet(trimmed); ok {
+			flush()
+			if paragraphBreak && len(blocks) > 0 {
+				blocks = append(blocks, "")
+			}
+			current, bullet = []string{text}, true
+		} else {
+			if len(current) == 0 {
+				if paragraphBreak && len(blocks) > 0 {
+					blocks = append(blocks, "")
+				}
+				bullet = false
+			}
+			current = append(current, trimmed)
+		}
+		paragraphBreak = false
+	}
+	flush()
+
+	return strings.Join(blocks, "\n")
+}
+
+// trimBullet strips a list marker, reporting whether the line had one
+func trimBullet(line string) (string, bool) {
+	for _, prefix := range bulletPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSpace(line[len(prefix):]), true
+		}
+	}
+	return line, false
+}
+
+// wrapText wraps text at width, starting the first line with first and
+// every following line with rest
+func wrapText(text string, width int, first, rest string) string {
+	var (
+		lines []string
+		line  = first
+		empty = true
+	)
+	for _, word := range strings.Fields(text) {
+		if !empty && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line, empty = rest, true
+		}
+		if !empty {
+			line += " "
+		}
+		line += word
+		empty = false
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n")
+}
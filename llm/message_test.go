@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestShortenSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{name: "short", subject: "fix: handle empty input.", want: "fix: handle empty input"},
+		{name: "word boundary", subject: "feat: " + strings.Repeat("word ", 20), want: "feat: " + strings.TrimSpace(strings.Repeat("word ", 13))},
+		// Without a space to cut at, the two-byte "é" straddles byte 72
+		{name: "multi-byte rune", subject: "x" + strings.Repeat("é", 40), want: "x" + strings.Repeat("é", 35)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shortenSubject(tt.subject)
+			if got != tt.want {
+				t.Errorf("shortenSubject() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > maxSubjectLength {
+				t.Errorf("shortenSubject() = %q is invalid UTF-8 or longer than %d bytes", got, maxSubjectLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ollamaBaseURL is the address a local Ollama server listens on
+const ollamaBaseURL = "http://localhost:11434"
+
+// ollamaProvider talks to the native Ollama API
+type ollamaProvider struct {
+	baseURL string
+	client  *http.Client
+
+	// available caches models confirmed to be pulled on the server
+	mu        sync.Mutex
+	available map[string]bool
+}
+
+// ollamaChatRequest represents the request structure for /api/chat
+type ollamaChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Options  struct {
+		NumPredict int `json:"num_predict,omitempty"`
+	} `json:"options"`
+}
+
+// ollamaChatResponse represents the non-streaming response from /api/chat
+type ollamaChatResponse struct {
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+}
+
+// ollamaTagsResponse lists the models pulled on the server
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	} `json:"models"`
+}
+
+// Complete checks that the model is available and sends the request to /api/chat
+func (p *ollamaProvider) Complete(ctx context.Context, completion CompletionRequest) (string, error) {
+	if err := p.checkModel(ctx, completion.Model, completion.APIKey); err != nil {
+		return "", err
+	}
+
+	chatReq := ollamaChatRequest{
+		Model: completion.Model,
+		Messages: []Message{
+			{Role: "system", Content: completion.System},
+			{Role: "user", Content: completion.Prompt},
+		},
+	}
+	chatReq.Options.NumPredict = completion.MaxTokens
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	resp, err := p.do(ctx, "POST", "/api/chat", completion.APIKey, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", ollamaError(resp)
+	}
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if chatResp.Message.Content == "" {
+		return "", fmt.Errorf("no response from LLM")
+	}
+
+	return chatResp.Message.Content, nil
+}
+
+// checkModel returns an error if the model has not been pulled on the server.
+// Successful checks are cached, so a model pulled later is picked up on the next run.
+func (p *ollamaProvider) checkModel(ctx context.Context, model, apiKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.available[model] {
+		return nil
+	}
+
+	resp, err := p.do(ctx, "GET", "/api/tags", apiKey, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ollamaError(resp)
+	}
+
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to decode model list: %w", err)
+	}
+
+	for _, m := range tags.Models {
+		// A model requested without a tag means the latest tag
+		if m.Name == model || m.Model == model || m.Name == model+":latest" {
+			if p.available == nil {
+				p.available = make(map[string]bool)
+			}
+			p.available[model] = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("model %q is not available on the Ollama server at %s; pull it with `ollama pull %s`", model, p.baseURL, model)
+}
+
+// do sends a request to the Ollama server
+func (p *ollamaProvider) do(ctx context.Context, method, path, apiKey string, body io.Reader) (*http.Response, error) {
+	endpoint := strings.TrimSuffix(p.baseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	// Ollama itself needs no key, but a proxy in front of it may
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, transportError(fmt.Errorf("failed to reach Ollama at %s: %w", p.baseURL, err))
+	}
+	return resp, nil
+}
+
+// ollamaError turns a non-200 response into an error
+func ollamaError(resp *http.Response) error {
+	var errorResponse struct {
+		Error string `json:"error"`
+	}
+	// The message is optional; the status code alone classifies the error
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+	return newAPIError(resp, errorResponse.Error)
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAIProvider talks to OpenAI-compatible /chat/completions endpoints
+type openAIProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Message represents a chat message in the API request
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest represents the request structure for chat models
+type ChatRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	MaxTokens int       `json:"max_tokens,omitempty"`
+}
+
+// ChatResponse represents the response structure from chat models
+type ChatResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// Complete sends the request as a chat completion
+func (p *openAIProvider) Complete(ctx context.Context, completion CompletionRequest) (string, error) {
+	// Prepare the request
+	chatReq := ChatRequest{
+		Model: completion.Model,
+		Messages: []Message{
+			{Role: "system", Content: completion.System},
+			{Role: "user", Content: completion.Prompt},
+		},
+		MaxTokens: completion.MaxTokens,
+	}
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	// Make HTTP request
+	endpoint := fmt.Sprintf("%s/chat/completions", strings.TrimSuffix(p.baseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+completion.APIKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", transportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		// The message is optional; the status code alone classifies the error
+		_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+		return "", newAPIError(resp, errorResponse.Error.Message)
+	}
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenAIComplete(t *testing.T) {
+	var got ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request = %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want %q", auth, "Bearer test-key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"choices": [{"message": {"role": "assistant", "content": "fix: handle empty input"}}]
+		}`))
+	}))
+	defer server.Close()
+
+	provider := &openAIProvider{baseURL: server.URL + "/v1", client: server.Client()}
+	message, err := provider.Complete(context.Background(), CompletionRequest{
+		APIKey:    "test-key",
+		Model:     "gpt-test",
+		System:    "You write commit messages.",
+		Prompt:    "diff --git a/input.go b/input.go",
+		MaxTokens: 100,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if message != "fix: handle empty input" {
+		t.Errorf("Complete() = %q, want %q", message, "fix: handle empty input")
+	}
+
+	want := []Message{
+		{Role: "system", Content: "You write commit messages."},
+		{Role: "user", Content: "diff --git a/input.go b/input.go"},
+	}
+	if got.Model != "gpt-test" || got.MaxTokens != 100 {
+		t.Errorf("model, max_tokens = %q, %d, want %q, 100", got.Model, got.MaxTokens, "gpt-test")
+	}
+	if len(got.Messages) != len(want) || got.Messages[0] != want[0] || got.Messages[1] != want[1] {
+		t.Errorf("messages = %+v, want %+v", got.Messages, want)
+	}
+}
+
+func TestOpenAICompleteErrors(t *testing.T) {
+	testCompleteErrors(t, func(baseURL string, client *http.Client) Provider {
+		return &openAIProvider{baseURL: baseURL, client: client}
+	}, `{"error": {"message": %q}}`)
+}
+
+// testCompleteErrors checks that error statuses map to the typed errors.
+// errorBody is the provider's error response with a %q for the message.
+func testCompleteErrors(t *testing.T, newProvider func(baseURL string, client *http.Client) Provider, errorBody string) {
+	tests := []struct {
+		name       string
+		status     int
+		message    string
+		retryAfter string
+		want       error
+		temporary  bool
+	}{
+		{name: "rate limited", status: http.StatusTooManyRequests, message: "slow down", retryAfter: "7", want: ErrRateLimited, temporary: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, message: "invalid x-api-key", want: ErrAuth},
+		{name: "forbidden", status: http.StatusForbidden, message: "no access", want: ErrAuth},
+		{name: "context length", status: http.StatusBadRequest, message: "prompt is too long: 250000 tokens", want: ErrContextLength},
+		{name: "too large", status: http.StatusRequestEntityTooLarge, want: ErrContextLength},
+		{name: "server error", status: http.StatusInternalServerError, message: "internal error", want: ErrServer, temporary: true},
+		{name: "overloaded", status: 529, message: "overloaded", want: ErrServer, temporary: true},
+		{name: "bad request", status: http.StatusBadRequest, message: "unknown model", want: ErrRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				if tt.message != "" {
+					fmt.Fprintf(w, errorBody, tt.message)
+				}
+			}))
+			defer server.Close()
+
+			_, err := newProvider(server.URL, server.Client()).Complete(context.Background(), CompletionRequest{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Complete() error = %v, want %v", err, tt.want)
+			}
+			if IsTemporary(err) != tt.temporary {
+				t.Errorf("IsTemporary() = %v, want %v", IsTemporary(err), tt.temporary)
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Complete() error is %T, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message {
+				t.Errorf("status, message = %d, %q, want %d, %q", apiErr.StatusCode, apiErr.Message, tt.status, tt.message)
+			}
+			if tt.retryAfter != "" && apiErr.RetryAfter != 7*time.Second {
+				t.Errorf("RetryAfter = %v, want 7s", apiErr.RetryAfter)
+			}
+		})
+	}
+}
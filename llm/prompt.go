@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/tejzpr/commitmonk/config"
+	"github.com/tejzpr/commitmonk/db"
+)
+
+// DefaultPromptTemplate is used when neither the task nor the config names a template
+const DefaultPromptTemplate = `{{.Instructions}}
+{{if .Squashed}}
+The changes were first committed separately; write one message that covers all of these commits:
+{{range .Squashed}}- {{.}}
+{{end}}{{end}}
+{{if .Summarized}}The diff is too large to show, so here is a summary of the changes per file:{{else}}Diff:{{end}}
+{{.Diff}}`
+
+// PromptData is available to prompt templates
+type PromptData struct {
+	// Instructions are the default instructions, or the repository config file's llm.prompt
+	Instructions string
+	// Diff is the staged diff, condensed or summarized to fit the token budget
+	Diff string
+	// Format is the requested message format, oneline or full
+	Format string
+	// Summarized reports whether Diff holds per-file summaries instead of a diff
+	Summarized bool
+	// Files lists the paths touched by the diff
+	Files []string
+	// Repo is the repository directory name
+	Repo   string
+	Branch string
+	// RecentCommits holds the subjects of the latest commits, newest first
+	RecentCommits []string
+	// Squashed holds the subjects of the commits being squashed, oldest
+	// first; it is empty for regular commits
+	Squashed []string
+	// Task holds the task's effective settings
+	Task db.Task
+}
+
+// promptFuncs are available to prompt templates in addition to the builtins
+var promptFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// regexFind returns the first match of pattern in s, e.g. a Jira key in a branch name
+	"regexFind": func(pattern, s string) (string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		return re.FindString(s), nil
+	},
+}
+
+// ParsePromptTemplate parses prompt template text
+func ParsePromptTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// LoadPromptTemplate reads and parses a prompt template file
+func LoadPromptTemplate(path string) (*template.Template, error) {
+	path, err := config.ExpandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+	}
+
+	tmpl, err := ParsePromptTemplate(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// promptTemplate returns the task's template, the configured default, or
+// DefaultPromptTemplate. Files are read on every use so edits apply to the next run.
+func (c *Client) promptTemplate(opts Options) (*template.Template, error) {
+	switch {
+	case opts.TemplateFile != "":
+		return LoadPromptTemplate(opts.TemplateFile)
+	case c.keyConfig.PromptTemplate != "":
+		return LoadPromptTemplate(c.keyConfig.PromptTemplate)
+	}
+	return ParsePromptTemplate(DefaultPromptTemplate)
+}
+
+// renderPrompt executes a prompt template
+func renderPrompt(tmpl *template.Template, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return buf.String(), nil
+}
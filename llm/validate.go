@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Commit message styles accepted in the style key of the [llm] config section
+const (
+	// StyleConventional requires Conventional Commits subjects
+	StyleConventional = "conventional"
+	// StyleNone only checks the subject length
+	StyleNone = "none"
+)
+
+// conventionalHeader matches "type(scope)!: description"
+var conventionalHeader = regexp.MustCompile(`^([A-Za-z]+)(\([^()\s][^()]*\))?(!)?: (\S.*)$`)
+
+// imperativeVerbs are common subject verbs whose -s, -ed and -ing forms
+// indicate a subject that is not in the imperative mood
+var imperativeVerbs = []string{
+	"add", "adjust", "allow", "bump", "change", "clean", "correct", "create",
+	"delete", "disable", "document", "drop", "enable", "ensure", "extract",
+	"fix", "handle", "implement", "improve", "introduce", "merge", "move",
+	"optimize", "prevent", "refactor", "remove", "rename", "replace", "revert",
+	"simplify", "support", "update", "upgrade", "use",
+}
+
+// nonImperativeForms maps conjugated forms of imperativeVerbs to their base form
+var nonImperativeForms = func() map[string]string {
+	forms := make(map[string]string)
+	for _, verb := range imperativeVerbs {
+		stem := strings.TrimSuffix(verb, "e")
+		for _, form := range []string{verb + "s", verb + "es", stem + "ed", stem + "ing", verb + "ed", verb + "ing"} {
+			if form != verb {
+				forms[form] = verb
+			}
+		}
+	}
+	return forms
+}()
+
+// Rules configures commit message validation
+type Rules struct {
+	// Style is StyleConventional or StyleNone
+	Style string
+	// Types lists the allowed Conventional Commits types
+	Types []string
+	// MaxSubjectLength is the longest allowed subject line
+	MaxSubjectLength int
+}
+
+// ValidationError reports a message that still breaks the rules after every repair attempt
+type ValidationError struct {
+	Message    string
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("commit message %q is invalid: %s", subjectLine(e.Message), strings.Join(e.Violations, "; "))
+}
+
+// Validate returns the ways message breaks the rules, or nil if it follows them
+func Validate(message string, rules Rules) []string {
+	subject := subjectLine(message)
+	if subject == "" {
+		return []string{"the message is empty"}
+	}
+
+	var violations []string
+	if rules.MaxSubjectLength > 0 && len(subject) > rules.MaxSubjectLength {
+		violations = append(violations, fmt.Sprintf("the subject is %d characters long; the limit is %d", len(subject), rules.MaxSubjectLength))
+	}
+	if strings.HasSuffix(subject, ".") {
+		violations = append(violations, "the subject ends with a period")
+	}
+	if lines := strings.SplitN(message, "\n", 3); len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		violations = append(violations, "the subject is not followed by a blank line")
+	}
+
+	if rules.Style != StyleConventional {
+		return violations
+	}
+
+	match := conventionalHeader.FindStringSubmatch(subject)
+	if match == nil {
+		return append(violations, `the subject does not start with "type: description" or "type(scope): description"`)
+	}
+
+	commitType, description := match[1], match[4]
+	if !containsString(rules.Types, commitType) {
+		violations = append(violations, fmt.Sprintf("%q is not an allowed type (allowed: %s)", commitType, strings.Join(rules.Types, ", ")))
+	}
+
+	firstWord := strings.ToLower(strings.Fields(description)[0])
+	if verb, ok := nonImperativeForms[firstWord]; ok {
+		violations = append(violations, fmt.Sprintf("the description should use the imperative mood (%q instead of %q)", verb, firstWord))
+	}
+
+	return violations
+}
+
+// repairPrompt asks the model to correct a reply that broke the rules
+func repairPrompt(prompt, reply string, violations []string) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nYour previous reply was:\n")
+	b.WriteString(reply)
+	b.WriteString("\n\nIt has these problems:\n")
+	for _, violation := range violations {
+		fmt.Fprintf(&b, "- %s\n", violation)
+	}
+	b.WriteString("\nWrite a corrected commit message. Respond with ONLY the commit message.")
+	return b.String()
+}
+
+// subjectLine returns the first line of a message
+func subjectLine(message string) string {
+	message = strings.TrimSpace(message)
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+	}
+	return strings.TrimSpace(message)
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
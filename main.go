@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,7 +14,12 @@ import (
 )
 
 func main() {
-	// Create CLI app with global verbose flag
+	// Commands are built before flags are parsed, so they share these and
+	// see the loaded configuration and database once Before has run
+	cfg := config.DefaultConfig()
+	database := &db.DB{}
+
+	// Create CLI app with global flags
 	app := &cli.App{
 		Name:  "commitmonk",
 		Usage: "Automated Git commit tool",
@@ -23,38 +29,52 @@ func main() {
 				Aliases: []string{"v"},
 				Usage:   "Enable verbose logging",
 			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to the config file (overrides " + config.EnvConfigFile + ")",
+			},
+			&cli.StringFlag{
+				Name:  "db",
+				Usage: "Path to the database file (overrides " + config.EnvDatabase + ")",
+			},
 		},
 		Before: func(c *cli.Context) error {
 			// Initialize logger with verbose flag
 			logger.Init(c.Bool("verbose"))
-			return nil
-		},
-	}
 
-	// Ensure config directory exists
-	configDir, err := config.GetConfigDir()
-	if err != nil {
-		log.Fatalf("Failed to get config directory: %v", err)
-	}
+			// Load configuration
+			configPath, _, err := config.ResolveConfigFilePath(c.String("config"))
+			if err != nil {
+				return fmt.Errorf("failed to locate config file: %w", err)
+			}
+			// Running on defaults would let the next save overwrite the file
+			loaded, err := config.LoadConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			*cfg = *loaded
 
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		log.Fatalf("Failed to create config directory: %v", err)
-	}
+			// Ensure database directory exists
+			dbPath, _, err := config.ResolveDatabasePath(c.String("db"))
+			if err != nil {
+				return fmt.Errorf("failed to locate database: %w", err)
+			}
+			if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+				return fmt.Errorf("failed to create database directory: %w", err)
+			}
 
-	// Initialize DB
-	dbPath := filepath.Join(configDir, "commitmonk.db")
-	database, err := db.InitDB(dbPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer database.Close()
+			// Initialize DB
+			opened, err := db.InitDB(dbPath)
+			if err != nil {
+				return fmt.Errorf("failed to initialize database: %w", err)
+			}
+			*database = *opened
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Errorf("Warning: Failed to load config: %v", err)
-		// Continue with default config
-		cfg = config.DefaultConfig()
+			return nil
+		},
+		After: func(c *cli.Context) error {
+			return database.Close()
+		},
 	}
 
 	// Add commands to app
@@ -64,6 +84,9 @@ func main() {
 		cmd.ListCommand(database),
 		cmd.ConfigCommand(cfg),
 		cmd.RunCommand(database, cfg),
+		cmd.HistoryCommand(database),
+		cmd.PromptCommand(database, cfg),
+		cmd.SquashCommand(database, cfg),
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,198 @@
+package repoconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/tejzpr/commitmonk/config"
+	"github.com/tejzpr/commitmonk/db"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames lists the per-repository config files, in lookup order
+var FileNames = []string{".commitmonk.yml", ".commitmonk.yaml"}
+
+// File represents a checked-in .commitmonk.yml that overrides task settings
+// for everyone working on the repository
+type File struct {
+	// Interval replaces the task's commit interval
+	Interval string `yaml:"interval"`
+	// Cron replaces the task's schedule with a cron expression
+	Cron     string `yaml:"cron"`
+	Timezone string `yaml:"timezone"`
+	// Exclude replaces the task's exclude patterns
+	Exclude []string `yaml:"exclude"`
+	// Message is a text/template for the static commit message
+	Message string `yaml:"message"`
+	// Push enables or disables auto-push
+	Push *bool    `yaml:"push"`
+	LLM  LLMRules `yaml:"llm"`
+	// Branches restricts which checked-out branches are committed to
+	Branches BranchRules `yaml:"branches"`
+
+	path string
+}
+
+// LLMRules overrides commit message generation
+type LLMRules struct {
+	Model  string `yaml:"model"`
+	Prompt string `yaml:"prompt"`
+	// Format selects a oneline or full (subject and body) message
+	Format string `yaml:"format"`
+	// Style selects the convention messages are validated against: conventional or none
+	Style string `yaml:"style"`
+}
+
+// BranchRules decides which branches commitmonk may commit to
+type BranchRules struct {
+	// Only, when set, allows commits only on branches matching one of these globs
+	Only []string `yaml:"only"`
+	// Skip prevents commits on branches matching any of these globs
+	Skip []string `yaml:"skip"`
+}
+
+// MessageData is available to the message template
+type MessageData struct {
+	Repo   string
+	Branch string
+	Date   string
+	Time   time.Time
+}
+
+// Load reads the repository's config file. It returns nil without an error
+// when the repository has none.
+func Load(repoPath string) (*File, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(repoPath, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		var file File
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		file.path = path
+
+		if err := file.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return &file, nil
+	}
+	return nil, nil
+}
+
+// Name returns the file's name relative to the repository root
+func (f *File) Name() string {
+	return filepath.Base(f.path)
+}
+
+// validate checks values that can be verified without the scheduler
+func (f *File) validate() error {
+	if f.Interval != "" && f.Cron != "" {
+		return fmt.Errorf("interval and cron cannot be used together")
+	}
+	if f.Interval != "" {
+		if err := config.ValidateInterval(f.Interval); err != nil {
+			return err
+		}
+	}
+	if f.Cron != "" {
+		if _, err := config.ParseCron(f.Cron, f.Timezone); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range append(append([]string{}, f.Branches.Only...), f.Branches.Skip...) {
+		if _, err := glob.Compile(pattern, '/'); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s': %w", pattern, err)
+		}
+	}
+	if f.LLM.Format != "" && f.LLM.Format != "oneline" && f.LLM.Format != "full" {
+		return fmt.Errorf("invalid llm format %q: must be oneline or full", f.LLM.Format)
+	}
+	if f.LLM.Style != "" && f.LLM.Style != "conventional" && f.LLM.Style != "none" {
+		return fmt.Errorf("invalid llm style %q: must be conventional or none", f.LLM.Style)
+	}
+	if f.Message != "" {
+		if _, err := template.New("message").Parse(f.Message); err != nil {
+			return fmt.Errorf("invalid message template: %w", err)
+		}
+	}
+	return nil
+}
+
+// Apply returns task with the file's overrides applied. A nil file leaves the task unchanged.
+func (f *File) Apply(task db.Task) db.Task {
+	if f == nil {
+		return task
+	}
+
+	if f.Interval != "" {
+		task.Every = f.Interval
+		task.Cron = ""
+		task.Timezone = ""
+	}
+	if f.Cron != "" {
+		task.Cron = f.Cron
+		task.Timezone = f.Timezone
+		task.Watch = false
+	}
+	if f.Exclude != nil {
+		task.ExcludePatterns = strings.Join(f.Exclude, ",")
+	}
+	if f.Push != nil {
+		task.AutoPush = *f.Push
+	}
+	return task
+}
+
+// AllowsBranch reports whether commits may be made on the given branch
+func (f *File) AllowsBranch(branch string) bool {
+	if f == nil {
+		return true
+	}
+
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			// Patterns were validated on load
+			g, err := glob.Compile(pattern, '/')
+			if err == nil && g.Match(branch) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(f.Branches.Only) > 0 && !matches(f.Branches.Only) {
+		return false
+	}
+	return !matches(f.Branches.Skip)
+}
+
+// RenderMessage renders the message template, returning "" if the file sets none
+func (f *File) RenderMessage(data MessageData) (string, error) {
+	if f == nil || f.Message == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New("message").Parse(f.Message)
+	if err != nil {
+		return "", fmt.Errorf("invalid message template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render message template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
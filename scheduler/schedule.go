@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tejzpr/commitmonk/config"
+	"github.com/tejzpr/commitmonk/db"
+)
+
+// NextRun returns the next time a task should fire after from
+func NextRun(task db.Task, from time.Time) (time.Time, error) {
+	if task.Cron != "" {
+		schedule, err := config.ParseCron(task.Cron, task.Timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := schedule.Next(from)
+		if next.IsZero() {
+			return time.Time{}, fmt.Errorf("cron expression %q never fires", task.Cron)
+		}
+		return next, nil
+	}
+
+	duration, err := time.ParseDuration(task.Every)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid interval %q: %w", task.Every, err)
+	}
+	return from.Add(duration), nil
+}
+
+// DescribeSchedule returns a short human readable form of a task's schedule
+func DescribeSchedule(task db.Task) string {
+	if task.Cron == "" {
+		return "every " + task.Every
+	}
+	if task.Timezone != "" {
+		return fmt.Sprintf("cron %q %s", task.Cron, task.Timezone)
+	}
+	return fmt.Sprintf("cron %q", task.Cron)
+}
+
+// scheduleChanged reports whether a task update requires recomputing its next run
+func scheduleChanged(old, updated db.Task) bool {
+	return old.Every != updated.Every ||
+		old.Cron != updated.Cron ||
+		old.Timezone != updated.Timezone
+}
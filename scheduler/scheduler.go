@@ -1,15 +1,22 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tejzpr/commitmonk/config"
 	"github.com/tejzpr/commitmonk/db"
 	"github.com/tejzpr/commitmonk/git"
+	"github.com/tejzpr/commitmonk/heuristic"
 	"github.com/tejzpr/commitmonk/llm"
 	"github.com/tejzpr/commitmonk/logger"
+	"github.com/tejzpr/commitmonk/repoconfig"
+	"github.com/tejzpr/commitmonk/secrets"
 )
 
 // TaskRunner handles the execution of repository tasks
@@ -20,22 +27,70 @@ type TaskRunner struct {
 	tasks     map[int64]*taskState
 	// Add lastCheck timestamp to track when we last checked for DB changes
 	lastCheck time.Time
+	// slots limits the number of tasks executing at once across all repositories
+	slots chan struct{}
+	// loopDone is closed when the scheduling loop has exited
+	loopDone chan struct{}
+	// ctx is cancelled when the shutdown deadline passes to abort long operations
+	ctx    context.Context
+	cancel context.CancelFunc
+	// inflight tracks dispatched task goroutines
+	inflight sync.WaitGroup
+
+	// deferLLMErrors postpones commits while the LLM is temporarily failing
+	deferLLMErrors bool
+	// secretScanner redacts secrets from diffs before they reach the LLM
+	secretScanner *secrets.Scanner
+
+	// mu guards running, queued, deferred and interrupted, which are shared with executing goroutines
+	mu          sync.Mutex
+	running     map[int64]bool
+	queued      map[int64]db.Task
+	deferred    map[int64]bool
+	interrupted []string
 }
 
 // taskState tracks the state of a running task
 type taskState struct {
 	task    db.Task
 	nextRun time.Time
+	// watcher, settle and maxStaleness are set for tasks running in watch mode
+	watcher      *repoWatcher
+	settle       time.Duration
+	maxStaleness time.Duration
 }
 
 // NewTaskRunner creates a new task runner
 func NewTaskRunner(database *db.DB, cfg *config.Config) *TaskRunner {
+	maxConcurrent := cfg.Scheduler.MaxConcurrent
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	secretScanner, err := secrets.NewScanner(cfg.SecretPatterns)
+	if err != nil {
+		logger.Errorf("Ignoring custom secret patterns: %v", err)
+		secretScanner, _ = secrets.NewScanner(nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &TaskRunner{
 		database:  database,
 		llmClient: llm.NewClient(cfg.LLM),
 		stopCh:    make(chan struct{}),
 		tasks:     make(map[int64]*taskState),
 		lastCheck: time.Now(),
+		slots:     make(chan struct{}, maxConcurrent),
+		loopDone:  make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		running:   make(map[int64]bool),
+		queued:    make(map[int64]db.Task),
+		deferred:  make(map[int64]bool),
+
+		deferLLMErrors: cfg.Scheduler.OnLLMError == "defer",
+		secretScanner:  secretScanner,
 	}
 }
 
@@ -54,10 +109,108 @@ func (r *TaskRunner) Start() error {
 	return nil
 }
 
-// Stop stops the task scheduler
-func (r *TaskRunner) Stop() {
+// cancelGrace is how long Stop waits for cancelled runs to reach a safe point
+const cancelGrace = 5 * time.Second
+
+// Stop stops scheduling new runs and waits up to timeout for in-flight runs
+// to finish. Runs still going at the deadline are cancelled at their next safe
+// point, and are given up on if they do not stop within cancelGrace. Stop
+// returns the paths of repositories whose runs were interrupted or given up on.
+func (r *TaskRunner) Stop(timeout time.Duration) []string {
 	close(r.stopCh)
+	<-r.loopDone
+
+	for _, state := range r.tasks {
+		state.stopWatching()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Printf("Shutdown deadline of %s reached, cancelling in-flight tasks", timeout)
+		r.cancel()
+		select {
+		case <-done:
+		case <-time.After(cancelGrace):
+			logger.Printf("Tasks still running %s after cancelling, not waiting for them", cancelGrace)
+		}
+	}
+	r.cancel()
+
+	r.mu.Lock()
+	interrupted := append([]string(nil), r.interrupted...)
+	for id := range r.running {
+		// The loop has exited, so tasks is no longer changing
+		if state, ok := r.tasks[id]; ok && !containsString(interrupted, state.task.Path) {
+			interrupted = append(interrupted, state.task.Path)
+		}
+	}
+	r.mu.Unlock()
+
 	logger.Println("Task scheduler stopped")
+	return interrupted
+}
+
+// stopping reports whether Stop has been called
+func (r *TaskRunner) stopping() bool {
+	select {
+	case <-r.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// startWatching subscribes to filesystem events for a watch-mode task
+func (s *taskState) startWatching() error {
+	if !s.task.Watch {
+		return nil
+	}
+
+	settle, err := time.ParseDuration(s.task.Settle)
+	if err != nil {
+		return fmt.Errorf("invalid settle window %q: %w", s.task.Settle, err)
+	}
+	maxStaleness, err := time.ParseDuration(s.task.Every)
+	if err != nil {
+		return fmt.Errorf("invalid interval %q: %w", s.task.Every, err)
+	}
+
+	watcher, err := newRepoWatcher(s.task.Path, s.task.ExcludePatterns)
+	if err != nil {
+		return err
+	}
+
+	s.watcher = watcher
+	s.settle = settle
+	s.maxStaleness = maxStaleness
+	return nil
+}
+
+// stopWatching releases the filesystem watcher, if any
+func (s *taskState) stopWatching() {
+	if s.watcher == nil {
+		return
+	}
+	if err := s.watcher.Close(); err != nil {
+		logger.Printf("Error closing watcher for %s: %v", s.task.Path, err)
+	}
+	s.watcher = nil
+}
+
+// watchSettingsChanged reports whether a task update requires a new watcher
+func watchSettingsChanged(old, updated db.Task) bool {
+	return old.Watch != updated.Watch ||
+		old.Settle != updated.Settle ||
+		old.Every != updated.Every ||
+		old.Path != updated.Path ||
+		old.ExcludePatterns != updated.ExcludePatterns
 }
 
 // loadTasks loads all tasks from the database
@@ -74,24 +227,54 @@ func (r *TaskRunner) loadTasks() error {
 	for _, task := range tasks {
 		currentTaskIDs[task.ID] = true
 
+		// Schedule using the repository's own config file, if any
+		merged, _, err := EffectiveTask(task)
+		if err != nil {
+			logger.Printf("Warning: Ignoring repository config for %s: %v", task.Path, err)
+		}
+		task = merged
+
 		// Check if we already have this task
 		if existingState, exists := r.tasks[task.ID]; exists {
 			// Update the task data but keep the next run time if it's still in the future
+			if scheduleChanged(existingState.task, task) {
+				nextRun, err := NextRun(task, time.Now())
+				if err != nil {
+					// Keep running on the previous schedule rather than dropping the task
+					logger.Printf("Warning: Invalid schedule for task %d (%s), keeping %s: %v", task.ID, task.Path, DescribeSchedule(existingState.task), err)
+					task.Every = existingState.task.Every
+					task.Cron = existingState.task.Cron
+					task.Timezone = existingState.task.Timezone
+				} else {
+					existingState.nextRun = nextRun
+				}
+			}
+			restartWatch := watchSettingsChanged(existingState.task, task)
 			existingState.task = task
-			logger.Printf("Updated task: %s (ID: %d, every %s)", task.Path, task.ID, task.Every)
+			if restartWatch {
+				existingState.stopWatching()
+				if err := existingState.startWatching(); err != nil {
+					logger.Errorf("Error watching %s, falling back to interval mode: %v", task.Path, err)
+				}
+			}
+			logger.Printf("Updated task: %s (ID: %d, %s)", task.Path, task.ID, DescribeSchedule(task))
 		} else {
 			// This is a new task, schedule its first run
-			duration, err := time.ParseDuration(task.Every)
+			nextRun, err := NextRun(task, time.Now())
 			if err != nil {
-				logger.Printf("Warning: Invalid duration for task %d (%s): %v", task.ID, task.Path, err)
+				logger.Printf("Warning: Invalid schedule for task %d (%s): %v", task.ID, task.Path, err)
 				continue
 			}
 
-			r.tasks[task.ID] = &taskState{
+			state := &taskState{
 				task:    task,
-				nextRun: time.Now().Add(duration), // Schedule next run
+				nextRun: nextRun, // Schedule next run
+			}
+			if err := state.startWatching(); err != nil {
+				logger.Errorf("Error watching %s, falling back to interval mode: %v", task.Path, err)
 			}
-			logger.Printf("Loaded new task: %s (ID: %d, every %s)", task.Path, task.ID, task.Every)
+			r.tasks[task.ID] = state
+			logger.Printf("Loaded new task: %s (ID: %d, %s)", task.Path, task.ID, DescribeSchedule(task))
 		}
 	}
 
@@ -99,6 +282,7 @@ func (r *TaskRunner) loadTasks() error {
 	for id := range r.tasks {
 		if !currentTaskIDs[id] {
 			logger.Printf("Removing task with ID %d as it's no longer in the database", id)
+			r.tasks[id].stopWatching()
 			delete(r.tasks, id)
 		}
 	}
@@ -122,6 +306,8 @@ func (r *TaskRunner) checkForChanges() error {
 
 // run is the main scheduler loop
 func (r *TaskRunner) run() {
+	defer close(r.loopDone)
+
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -145,89 +331,553 @@ func (r *TaskRunner) processTasks() {
 	now := time.Now()
 
 	for id, state := range r.tasks {
+		if state.watcher != nil {
+			r.processWatchedTask(id, state, now)
+			continue
+		}
+
 		if now.After(state.nextRun) {
 			// Execute task
-			go r.executeTask(state.task)
+			r.dispatch(state.task)
 
 			// Update next run time
-			duration, err := time.ParseDuration(state.task.Every)
+			nextRun, err := NextRun(state.task, now)
 			if err != nil {
-				logger.Printf("Error parsing duration for task %d: %v", id, err)
+				logger.Printf("Error computing next run for task %d: %v", id, err)
 				delete(r.tasks, id) // Remove invalid task
 				continue
 			}
-			r.tasks[id].nextRun = now.Add(duration)
+			r.tasks[id].nextRun = nextRun
+		}
+	}
+}
+
+// processWatchedTask commits a watch-mode task once its tree has been quiet for
+// the settle window, or once its oldest pending change is older than Every
+func (r *TaskRunner) processWatchedTask(id int64, state *taskState, now time.Time) {
+	// A deferred run has no new events to trigger it, so mark the tree changed again
+	r.mu.Lock()
+	deferred := r.deferred[id]
+	delete(r.deferred, id)
+	r.mu.Unlock()
+	if deferred {
+		state.watcher.touch()
+	}
+
+	dirty, firstEvent, lastEvent := state.watcher.pending()
+	if !dirty {
+		return
+	}
+
+	settled := now.Sub(lastEvent) >= state.settle
+	stale := now.Sub(firstEvent) >= state.maxStaleness
+	if !settled && !stale {
+		return
+	}
+
+	if stale && !settled {
+		logger.Printf("Changes in %s pending for over %s, committing without waiting to settle", state.task.Path, state.task.Every)
+	}
+
+	// The run in progress may have staged before the latest edits, so keep
+	// them pending until it finishes instead of skipping
+	r.mu.Lock()
+	running := r.running[id]
+	r.mu.Unlock()
+	if running {
+		return
+	}
+
+	// Reset before executing so edits made during the commit trigger another run
+	state.watcher.reset()
+	r.dispatch(state.task)
+}
+
+// dispatch starts a task unless a previous run of it is still in progress, in
+// which case the run is skipped or queued according to the task's overlap policy
+func (r *TaskRunner) dispatch(task db.Task) {
+	r.mu.Lock()
+	if r.running[task.ID] {
+		_, alreadyQueued := r.queued[task.ID]
+		outcome := db.OutcomeSkippedOverlap
+		if task.Overlap == db.OverlapQueue && !alreadyQueued {
+			r.queued[task.ID] = task
+			outcome = db.OutcomeQueuedOverlap
+		}
+		r.mu.Unlock()
+		r.recordOverlap(task, outcome)
+		return
+	}
+	r.running[task.ID] = true
+	r.mu.Unlock()
+
+	r.inflight.Add(1)
+	go func() {
+		defer r.inflight.Done()
+		for {
+			// Wait for a free slot, giving up if we are shutting down
+			select {
+			case r.slots <- struct{}{}:
+			case <-r.stopCh:
+				r.mu.Lock()
+				delete(r.running, task.ID)
+				delete(r.queued, task.ID)
+				r.mu.Unlock()
+				return
+			}
+			r.executeTask(task)
+			<-r.slots
+
+			// Run again if the task came due while we were busy
+			r.mu.Lock()
+			next, ok := r.queued[task.ID]
+			if !ok || r.stopping() {
+				delete(r.running, task.ID)
+				delete(r.queued, task.ID)
+				r.mu.Unlock()
+				return
+			}
+			delete(r.queued, task.ID)
+			r.mu.Unlock()
+			task = next
+		}
+	}()
+}
+
+// recordOverlap notes that a task came due while its previous run was still in progress
+func (r *TaskRunner) recordOverlap(task db.Task, outcome string) {
+	if outcome == db.OutcomeQueuedOverlap {
+		logger.Printf("Previous run for %s still in progress, queued another run", task.Path)
+	} else {
+		logger.Printf("Previous run for %s still in progress, skipping", task.Path)
+	}
+
+	now := time.Now()
+	run := db.Run{
+		TaskID:     task.ID,
+		Path:       task.Path,
+		StartedAt:  now,
+		FinishedAt: now,
+		Outcome:    outcome,
+		Error:      "previous run still in progress",
+	}
+	if err := r.database.AddRun(run); err != nil {
+		logger.Errorf("Error recording run for %s: %v", task.Path, err)
+	}
+}
+
+// EffectiveTask applies the repository's .commitmonk.yml to a task from the
+// database. If the file is invalid the task is returned unchanged with the error.
+func EffectiveTask(task db.Task) (db.Task, *repoconfig.File, error) {
+	file, err := repoconfig.Load(task.Path)
+	if err != nil {
+		return task, nil, err
+	}
+	if file == nil {
+		return task, nil, nil
+	}
+
+	merged := file.Apply(task)
+	if _, err := NextRun(merged, time.Now()); err != nil {
+		return task, nil, fmt.Errorf("invalid schedule in %s: %w", file.Name(), err)
+	}
+	return merged, file, nil
+}
+
+// recentCommitCount is how many commit subjects are passed to prompt templates
+const recentCommitCount = 10
+
+// LLMOptions returns the commit message generation settings for a task: the
+// overrides from its repository config file and the context for its prompt template
+func LLMOptions(task db.Task, repoFile *repoconfig.File, repoManager *git.RepoManager, branch string) llm.Options {
+	recent, err := repoManager.RecentSubjects(recentCommitCount)
+	if err != nil {
+		logger.Errorf("Error reading recent commits in %s: %v", task.Path, err)
+	}
+
+	opts := llm.Options{
+		TemplateFile:  task.PromptTemplate,
+		Repo:          filepath.Base(task.Path),
+		Branch:        branch,
+		RecentCommits: recent,
+		Task:          task,
+	}
+	if repoFile != nil {
+		opts.Model = repoFile.LLM.Model
+		opts.Instructions = repoFile.LLM.Prompt
+		opts.Format = repoFile.LLM.Format
+		opts.Style = repoFile.LLM.Style
+	}
+	return opts
+}
+
+// CommitOptions returns who a task's commits are attributed to: its author
+// override, if any, and how commitmonk itself is credited
+func CommitOptions(task db.Task) (git.CommitOptions, error) {
+	var opts git.CommitOptions
+	if task.Author != "" {
+		author, err := git.ParseIdentity(task.Author)
+		if err != nil {
+			return opts, err
+		}
+		opts.Author = author
+	}
+
+	switch task.Attribution {
+	case db.AttributionCoAuthor:
+		opts.CoAuthors = []git.Identity{git.BotIdentity}
+	case db.AttributionCommitter:
+		opts.Committer = git.BotIdentity
+	}
+	return opts, nil
+}
+
+// syncBeforePush integrates commits others have pushed to the remote branch
+// according to the task's sync strategy and records what it did on run. An
+// error wrapping git.ErrDiverged or git.ErrSyncConflict means the branch is
+// unchanged and must not be pushed.
+func syncBeforePush(ctx context.Context, task db.Task, run *db.Run, repoManager *git.RepoManager, target git.PushTarget, opts git.CommitOptions) error {
+	behind, err := repoManager.FetchBehind(ctx, target)
+	if errors.Is(err, git.ErrSyncUnavailable) {
+		// Pushing works without git, as it did before syncing existed
+		logger.Errorf("Warning: not syncing %s before pushing: %v", task.Path, err)
+		run.Sync = "not synced, git is not installed"
+		return nil
+	}
+	if err != nil || behind == 0 {
+		return err
+	}
+
+	switch task.SyncStrategy {
+	case db.SyncRebase:
+		logger.Printf("Rebasing %s onto %d new remote commits", task.Path, behind)
+		if err := repoManager.RebaseOnRemote(ctx, target, opts); err != nil {
+			return err
+		}
+		run.Sync = fmt.Sprintf("rebased onto %d remote commits", behind)
+		// The rebase rewrote the commit
+		hash, err := repoManager.HeadCommit()
+		if err != nil {
+			return err
+		}
+		run.CommitHash = hash
+	case db.SyncMerge:
+		logger.Printf("Merging %d new remote commits into %s", behind, task.Path)
+		if err := repoManager.MergeRemote(ctx, target, opts); err != nil {
+			return err
+		}
+		run.Sync = fmt.Sprintf("merged %d remote commits", behind)
+	default:
+		return fmt.Errorf("%w (%d new remote commits)", git.ErrDiverged, behind)
+	}
+	return nil
+}
+
+// stagingArea is where a run stages and commits changes: the repository's
+// index and checked-out branch, or a snapshot's temporary index and ref
+type stagingArea interface {
+	HasStagedChanges() (bool, error)
+	StagedChanges(ctx context.Context) ([]git.FileChange, error)
+	ScanStaged(ctx context.Context, scanner *secrets.Scanner) ([]secrets.Finding, error)
+	UnstageFiles(paths []string) error
+	ResetIndex() error
+	GetDiff(ctx context.Context) (string, error)
+	Commit(ctx context.Context, message string, opts git.CommitOptions) (string, error)
+	Push(ctx context.Context, target git.PushTarget) error
+}
+
+// heuristicMessage derives a commit message from the staged changes without an LLM
+func heuristicMessage(ctx context.Context, area stagingArea) (string, error) {
+	changes, err := area.StagedChanges(ctx)
+	if err != nil {
+		return "", err
+	}
+	return heuristic.Message(changes), nil
+}
+
+// handleSecrets applies the task's secret policy to findings in the staged
+// changes and records them on run. It reports whether the run should go on
+// to commit what is still staged.
+func (r *TaskRunner) handleSecrets(task db.Task, run *db.Run, area stagingArea, findings []secrets.Finding, staged bool) bool {
+	lines := make([]string, len(findings))
+	for i, finding := range findings {
+		lines[i] = finding.String()
+		logger.Errorf("Secret in staged changes in %s: %s", task.Path, finding)
+	}
+	run.Secrets = strings.Join(lines, "\n")
+	blocked := fmt.Sprintf("%d secrets found in staged changes; add their fingerprints to %s to allow them", len(findings), secrets.AllowlistFile)
+
+	if task.SecretPolicy == db.SecretPolicyUnstage {
+		var files []string
+		seen := make(map[string]bool)
+		for _, finding := range findings {
+			if !seen[finding.File] {
+				seen[finding.File] = true
+				files = append(files, finding.File)
+			}
+		}
+		if err := area.UnstageFiles(files); err != nil {
+			logger.Errorf("Error unstaging files with secrets in %s: %v", task.Path, err)
+			run.Outcome = db.OutcomeFailed
+			run.Error = err.Error()
+			return false
+		}
+		logger.Printf("Unstaged %s in %s because they contain secrets", strings.Join(files, ", "), task.Path)
+
+		hasStagedChanges, err := area.HasStagedChanges()
+		if err != nil {
+			logger.Errorf("Error checking for staged changes in %s: %v", task.Path, err)
+			run.Outcome = db.OutcomeFailed
+			run.Error = err.Error()
+			return false
+		}
+		if hasStagedChanges {
+			return true
 		}
 	}
+
+	logger.Errorf("Not committing in %s: %s", task.Path, blocked)
+	run.Outcome = db.OutcomeBlockedSecrets
+	run.Error = blocked
+	if staged {
+		if err := area.ResetIndex(); err != nil {
+			logger.Errorf("Error unstaging changes in %s: %v", task.Path, err)
+		}
+	}
+	return false
 }
 
-// executeTask processes a single repository task
+// executeTask processes a single repository task and records the outcome
 func (r *TaskRunner) executeTask(task db.Task) {
+	run := db.Run{
+		TaskID:    task.ID,
+		Path:      task.Path,
+		StartedAt: time.Now(),
+	}
+	r.runTask(r.ctx, task, &run)
+
+	run.FinishedAt = time.Now()
+	switch run.Outcome {
+	case db.OutcomeInterrupted:
+		r.mu.Lock()
+		r.interrupted = append(r.interrupted, task.Path)
+		r.mu.Unlock()
+	case db.OutcomeDeferred:
+		r.mu.Lock()
+		r.deferred[task.ID] = true
+		r.mu.Unlock()
+	}
+	if err := r.database.AddRun(run); err != nil {
+		logger.Errorf("Error recording run for %s: %v", task.Path, err)
+	}
+}
+
+// runTask performs the commit workflow for a task, filling in run as it goes.
+// When ctx is cancelled the task stops at the next safe point: changes staged
+// by this run are unstaged again unless they have already been committed.
+func (r *TaskRunner) runTask(ctx context.Context, task db.Task, run *db.Run) {
 	logger.Printf("Executing task for repository: %s", task.Path)
 
+	var (
+		repoManager *git.RepoManager
+		area        stagingArea
+		staged      bool
+	)
+
+	// fail logs an error about the repository and marks the run as failed,
+	// or as interrupted if the error was caused by shutdown
+	fail := func(format string, err error) {
+		if ctx.Err() == nil {
+			logger.Errorf(format, task.Path, err)
+			run.Outcome = db.OutcomeFailed
+			run.Error = err.Error()
+			return
+		}
+
+		logger.Errorf("Run for %s interrupted by shutdown: %v", task.Path, err)
+		run.Outcome = db.OutcomeInterrupted
+		run.Error = fmt.Sprintf("interrupted by shutdown: %v", err)
+		if staged {
+			if err := area.ResetIndex(); err != nil {
+				logger.Errorf("Error unstaging changes in %s: %v", task.Path, err)
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		fail("Error starting run for %s: %v", err)
+		return
+	}
+
+	// Repository config file > database task > global config
+	task, repoFile, err := EffectiveTask(task)
+	if err != nil {
+		logger.Errorf("Ignoring repository config for %s: %v", task.Path, err)
+	}
+
 	// Create repository manager
-	repoManager, err := git.NewRepoManager(task.Path)
+	repoManager, err = git.NewRepoManager(task.Path)
 	if err != nil {
-		logger.Errorf("Error opening repository %s: %v", task.Path, err)
+		fail("Error opening repository %s: %v", err)
+		return
+	}
+
+	// Honor branch rules from the repository config file
+	branch, err := repoManager.CurrentBranch()
+	if err != nil {
+		fail("Error reading current branch in %s: %v", err)
+		return
+	}
+	if !repoFile.AllowsBranch(branch) {
+		logger.Printf("Branch %q in %s is excluded by %s, skipping", branch, task.Path, repoFile.Name())
+		run.Outcome = db.OutcomeSkippedBranch
 		return
 	}
 
+	// Render the repository's commit message template
+	message, err := repoFile.RenderMessage(repoconfig.MessageData{
+		Repo:   filepath.Base(task.Path),
+		Branch: branch,
+		Date:   run.StartedAt.Format("2006-01-02"),
+		Time:   run.StartedAt,
+	})
+	if err != nil {
+		fail("Error rendering commit message for %s: %v", err)
+		return
+	}
+	if message != "" {
+		task.StaticMsg = message
+	}
+
 	// Check for changes
 	hasChanges, err := repoManager.HasChanges()
 	if err != nil {
-		logger.Errorf("Error checking for changes in %s: %v", task.Path, err)
+		fail("Error checking for changes in %s: %v", err)
 		return
 	}
 
 	if !hasChanges {
 		logger.Printf("No changes detected in %s, skipping", task.Path)
+		run.Outcome = db.OutcomeSkippedNoChanges
 		return
 	}
 
 	// Stage changes if configured
-	if task.AutoAdd {
+	if task.Snapshot {
+		logger.Printf("Staging a snapshot of %s", task.Path)
+		snapshot, err := repoManager.NewSnapshot(ctx, branch, task.AutoAdd, task.ExcludePatterns)
+		if err != nil {
+			fail("Error staging a snapshot of %s: %v", err)
+			return
+		}
+		defer snapshot.Close()
+		area = snapshot
+
+		hasStagedChanges, err := snapshot.HasStagedChanges()
+		if err != nil {
+			fail("Error checking for changes since the last snapshot of %s: %v", err)
+			return
+		}
+		if !hasStagedChanges {
+			logger.Printf("No changes since the last snapshot of %s, skipping", task.Path)
+			run.Outcome = db.OutcomeSkippedNoChanges
+			return
+		}
+	} else if task.AutoAdd {
+		area = repoManager
 		logger.Printf("Auto-staging changes in %s", task.Path)
-		if err := repoManager.StageChanges(task.ExcludePatterns); err != nil {
-			logger.Errorf("Error staging changes in %s: %v", task.Path, err)
+		if err := repoManager.StageChanges(ctx, task.ExcludePatterns); err != nil {
+			fail("Error staging changes in %s: %v", err)
 			return
 		}
+		staged = true
 	} else {
+		area = repoManager
 		// If auto-add is not enabled, check if there are already staged changes
 		hasStagedChanges, err := repoManager.HasStagedChanges()
 		if err != nil {
-			logger.Errorf("Error checking for staged changes in %s: %v", task.Path, err)
+			fail("Error checking for staged changes in %s: %v", err)
 			return
 		}
 
 		// If no staged changes and auto-add is disabled, skip this task
 		if !hasStagedChanges {
 			logger.Printf("No staged changes in %s and auto-add is disabled, skipping", task.Path)
+			run.Outcome = db.OutcomeSkippedNoChanges
+			return
+		}
+	}
+
+	// Refuse to commit secrets introduced by the staged changes
+	if task.SecretPolicy != db.SecretPolicyOff {
+		findings, err := area.ScanStaged(ctx, r.secretScanner)
+		if err != nil {
+			fail("Error scanning staged changes in %s: %v", err)
+			return
+		}
+		if len(findings) > 0 && !r.handleSecrets(task, run, area, findings, staged) {
 			return
 		}
 	}
 
 	// Get diff for LLM
-	diff, err := repoManager.GetDiff()
+	diff, err := area.GetDiff(ctx)
 	if err != nil {
-		logger.Errorf("Error getting diff for %s: %v", task.Path, err)
+		fail("Error getting diff for %s: %v", err)
 		return
 	}
 
+	// Never send secrets to the LLM
+	useLLM := r.llmClient.HasCredentials()
+	diff, findings := r.secretScanner.RedactDiff(diff)
+	if len(findings) > 0 {
+		logger.Printf("Redacted %d possible secrets (%s) from the diff for %s", len(findings), strings.Join(secrets.Rules(findings), ", "), task.Path)
+		if useLLM && task.SkipLLMOnSecrets {
+			logger.Printf("Not sending the diff for %s to the LLM because it contains secrets", task.Path)
+			useLLM = false
+		}
+	}
+
 	// Determine commit message
 	var commitMsg string
 
 	// If LLM is configured, always try to use it first regardless of static message
-	if r.llmClient.HasCredentials() {
+	if useLLM {
 		logger.Printf("Generating commit message using LLM for %s", task.Path)
-		commitMsg, err = r.llmClient.GenerateCommitMessage(diff)
+		commitMsg, run.Endpoint, err = r.llmClient.GenerateCommitMessage(ctx, diff, LLMOptions(task, repoFile, repoManager, branch))
+		run.MessageSource = db.MessageSourceLLM
+		if err != nil && ctx.Err() != nil {
+			fail("Error generating commit message for %s: %v", err)
+			return
+		}
+		if err != nil && r.deferLLMErrors && llm.IsTemporary(err) {
+			logger.Printf("LLM unavailable for %s, deferring the commit to the next run: %v", task.Path, err)
+			run.Outcome = db.OutcomeDeferred
+			run.Error = err.Error()
+			if staged {
+				if err := area.ResetIndex(); err != nil {
+					logger.Errorf("Error unstaging changes in %s: %v", task.Path, err)
+				}
+			}
+			return
+		}
 		if err != nil {
 			logger.Errorf("Error generating commit message for %s: %v", task.Path, err)
 			// Fall back to static message if provided
 			if task.StaticMsg != "" {
 				logger.Printf("Falling back to static message for %s", task.Path)
 				commitMsg = task.StaticMsg
+				run.MessageSource = db.MessageSourceStatic
+			} else if task.Fallback != db.FallbackNone {
+				commitMsg, err = heuristicMessage(ctx, area)
+				if err != nil {
+					fail("Error deriving a fallback commit message for %s: %v", err)
+					return
+				}
+				logger.Printf("Falling back to %q for %s", commitMsg, task.Path)
+				run.MessageSource = db.MessageSourceHeuristic
 			} else {
-				logger.Errorf("LLM failed and no static message configured for %s, cannot commit", task.Path)
+				fail("LLM failed and no static message configured for %s, cannot commit: %v", err)
 				return // Don't commit if no message is available
 			}
 		}
@@ -235,30 +885,98 @@ func (r *TaskRunner) executeTask(task db.Task) {
 		// Use static message if LLM is not configured
 		logger.Printf("Using configured static message for %s", task.Path)
 		commitMsg = task.StaticMsg
+		run.MessageSource = db.MessageSourceStatic
+	} else if task.Fallback != db.FallbackNone {
+		commitMsg, err = heuristicMessage(ctx, area)
+		if err != nil {
+			fail("Error deriving a commit message for %s: %v", err)
+			return
+		}
+		logger.Printf("Using derived message %q for %s", commitMsg, task.Path)
+		run.MessageSource = db.MessageSourceHeuristic
+	} else if r.llmClient.HasCredentials() {
+		fail("Cannot commit in %s: %v", fmt.Errorf("the diff contains secrets and no static message is configured"))
+		return // Don't commit if no message is available
 	} else {
-		logger.Errorf("No LLM credentials and no static message configured for %s, cannot commit", task.Path)
+		fail("Cannot commit in %s: %v", fmt.Errorf("no LLM configured and no static message configured"))
 		return // Don't commit if no message is available
 	}
+	run.Message = commitMsg
 
 	// Commit changes
-	err = repoManager.Commit(commitMsg)
+	commitOpts, err := CommitOptions(task)
+	if err != nil {
+		fail("Error committing changes in %s: %v", err)
+		return
+	}
+	// Marks the commit as commitmonk's even under the user's identity
+	commitOpts.Run = fmt.Sprintf("%d@%s", task.ID, run.StartedAt.UTC().Format(time.RFC3339))
+	hash, err := area.Commit(ctx, commitMsg, commitOpts)
 	if err != nil {
 		if strings.Contains(err.Error(), "no staged changes") {
 			logger.Printf("No staged changes to commit in %s", task.Path)
+			run.Outcome = db.OutcomeSkippedNoChanges
 		} else {
-			logger.Errorf("Error committing changes in %s: %v", task.Path, err)
+			fail("Error committing changes in %s: %v", err)
 		}
 		return
 	}
-	logger.Printf("Created commit in %s: %s", task.Path, commitMsg)
+	if snapshot, ok := area.(*git.Snapshot); ok {
+		logger.Printf("Created snapshot in %s on %s: %s", task.Path, snapshot.Ref, commitMsg)
+	} else {
+		logger.Printf("Created commit in %s: %s", task.Path, commitMsg)
+	}
+	run.Outcome = db.OutcomeCommitted
+	run.CommitHash = hash
+	staged = false
 
 	// Push if configured
 	if task.AutoPush {
-		logger.Printf("Auto-pushing commits in %s", task.Path)
-		if err := repoManager.Push(); err != nil {
-			logger.Errorf("Error pushing changes in %s: %v", task.Path, err)
+		target, err := repoManager.ResolvePushTarget(ctx, task.PushRemote, task.PushBranch, task.Mirrors)
+		if err != nil {
+			fail("Error choosing where to push %s: %v", err)
 			return
 		}
+
+		// Snapshot refs are commitmonk's own, so there is nothing to integrate
+		if !task.Snapshot {
+			if err := syncBeforePush(ctx, task, run, repoManager, target, commitOpts); err != nil {
+				switch {
+				case errors.Is(err, git.ErrDiverged):
+					run.Outcome = db.OutcomeSyncDiverged
+				case errors.Is(err, git.ErrSyncConflict):
+					run.Outcome = db.OutcomeSyncConflict
+				default:
+					fail("Error syncing %s with the remote: %v", err)
+					return
+				}
+				logger.Errorf("Not pushing %s, the commit stays local: %v", task.Path, err)
+				run.Error = err.Error()
+				return
+			}
+		}
+
+		logger.Printf("Auto-pushing commits in %s to %s", task.Path, target.Remote)
+		if err := area.Push(ctx, target); err != nil {
+			if !errors.Is(err, git.ErrPushIncomplete) {
+				fail("Error pushing changes in %s: %v", err)
+				return
+			}
+			// The commit reached the remote, only the upstream or a mirror is missing
+			logger.Errorf("Error pushing changes in %s: %v", task.Path, err)
+			run.Error = err.Error()
+		}
 		logger.Printf("Successfully pushed commits in %s", task.Path)
+		run.Outcome = db.OutcomePushed
+	}
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/gobwas/glob"
+	"github.com/tejzpr/commitmonk/git"
+	"github.com/tejzpr/commitmonk/logger"
+)
+
+// repoWatcher tracks filesystem activity in a repository's working tree
+type repoWatcher struct {
+	root     string
+	excludes []glob.Glob
+	// gitignored is only used by the goroutine that handles events
+	gitignored gitignore.Matcher
+	fsw        *fsnotify.Watcher
+
+	mu         sync.Mutex
+	dirty      bool
+	firstEvent time.Time
+	lastEvent  time.Time
+}
+
+// newRepoWatcher starts watching every directory under root except .git,
+// excluded paths and paths git ignores
+func newRepoWatcher(root string, excludePatterns string) (*repoWatcher, error) {
+	excludes, err := git.CompileExcludePatterns(excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	gitignored, err := git.IgnoreMatcher(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filesystem watcher: %w", err)
+	}
+
+	now := time.Now()
+	w := &repoWatcher{
+		root:       root,
+		excludes:   excludes,
+		gitignored: gitignored,
+		fsw:        fsw,
+		// Treat the tree as dirty on start so changes made while we
+		// were not running are picked up after the first settle window
+		dirty:      true,
+		firstEvent: now,
+		lastEvent:  now,
+	}
+
+	if err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	go w.loop()
+
+	return w, nil
+}
+
+// Close stops the watcher
+func (w *repoWatcher) Close() error {
+	return w.fsw.Close()
+}
+
+// pending reports whether changes were seen since the last reset and when
+func (w *repoWatcher) pending() (dirty bool, firstEvent, lastEvent time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dirty, w.firstEvent, w.lastEvent
+}
+
+// reset clears the pending state before a commit is attempted
+func (w *repoWatcher) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dirty = false
+}
+
+// touch marks the tree as changed, as if a filesystem event had just arrived
+func (w *repoWatcher) touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	if !w.dirty {
+		w.dirty = true
+		w.firstEvent = now
+	}
+	w.lastEvent = now
+}
+
+// ignored reports whether a path should not trigger commits
+func (w *repoWatcher) ignored(path string, isDir bool) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+		return true
+	}
+	if git.MatchesExclude(w.excludes, rel) {
+		return true
+	}
+	return w.gitignored.Match(strings.Split(rel, "/"), isDir)
+}
+
+// addTree registers dir and all of its subdirectories with the watcher
+func (w *repoWatcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Directories can disappear while we walk them
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != w.root && w.ignored(path, true) {
+			return filepath.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// loop consumes filesystem events until the watcher is closed
+func (w *repoWatcher) loop() {
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Removed paths can no longer be checked, and count as files
+			info, err := os.Stat(event.Name)
+			isDir := err == nil && info.IsDir()
+			if w.ignored(event.Name, isDir) {
+				continue
+			}
+
+			// Pick up changed ignore rules for later events
+			if filepath.Base(event.Name) == ".gitignore" {
+				if gitignored, err := git.IgnoreMatcher(w.root); err != nil {
+					logger.Printf("Warning: %v", err)
+				} else {
+					w.gitignored = gitignored
+				}
+			}
+
+			// New directories are not watched automatically
+			if event.Op&fsnotify.Create != 0 && isDir {
+				if err := w.addTree(event.Name); err != nil {
+					logger.Printf("Warning: %v", err)
+				}
+			}
+
+			w.touch()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			logger.Printf("Watcher error in %s: %v", w.root, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// AllowlistFile is the file in a repository's root listing the fingerprints
+// of findings that may be committed, one per line
+const AllowlistFile = ".commitmonk-allowlist"
+
+// Fingerprint identifies a finding by file, rule and a hash of the secret,
+// so it stays the same when the secret moves within the file
+func (f Finding) Fingerprint() string {
+	sum := sha256.Sum256([]byte(f.Secret))
+	return f.File + ":" + f.Rule + ":" + hex.EncodeToString(sum[:8])
+}
+
+// String describes a finding without revealing the secret
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d %s (%s)", f.File, f.Line, f.Rule, f.Fingerprint())
+}
+
+// Allowlist holds the fingerprints of accepted findings
+type Allowlist map[string]bool
+
+// LoadAllowlist reads an allowlist file. Text after # is a comment. A
+// missing file is an empty allowlist.
+func LoadAllowlist(path string) (Allowlist, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Allowlist{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open allowlist: %w", err)
+	}
+	defer file.Close()
+
+	allowlist := Allowlist{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			allowlist[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read allowlist: %w", err)
+	}
+	return allowlist, nil
+}
+
+// Filter returns the findings that are not allowed
+func (a Allowlist) Filter(findings []Finding) []Finding {
+	var kept []Finding
+	for _, finding := range findings {
+		if !a[finding.Fingerprint()] {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
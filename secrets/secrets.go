@@ -0,0 +1,272 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule detects one kind of secret
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Group is the submatch holding the secret, or 0 for the whole match
+	Group int
+	// MinEntropy skips matches whose Shannon entropy in bits per character
+	// is lower, such as placeholders and variable names
+	MinEntropy float64
+	// Valid, if set, rejects matches that are not secrets
+	Valid func(secret string) bool
+}
+
+// DefaultRules detect common credential formats
+var DefaultRules = []Rule{
+	{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key\W{0,5}([A-Za-z0-9/+]{40})\b`), Group: 1},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{Name: "gitlab-token", Pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{Name: "stripe-key", Pattern: regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}\b`)},
+	{Name: "google-api-key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{Name: "llm-api-key", Pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`), MinEntropy: 3.5},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	// A block without its END line is redacted to the end of the text
+	{Name: "private-key", Pattern: regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|\z)`)},
+	{Name: "url-password", Pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:([^\s:/@]{3,})@`), Group: 1},
+	{Name: "secret-assignment", Pattern: regexp.MustCompile(`(?i)[\w.-]*(?:secret|token|passw(?:or)?d|pwd|api_?key|access_?key|credential|private_?key)[\w.-]*["']?\s*(?::|=|:=|=>)\s*["']?([A-Za-z0-9/+=_.~!@#$%^&*-]{8,})`), Group: 1, MinEntropy: 3.0, Valid: notIdentifier},
+	{Name: "high-entropy-string", Pattern: regexp.MustCompile(`["'` + "`" + `]([A-Za-z0-9+/=_-]{32,})["'` + "`" + `]`), Group: 1, MinEntropy: 4.5},
+}
+
+// dotenvRule names values redacted from .env files, whatever they look like
+const dotenvRule = "dotenv-value"
+
+// Finding is a detected secret
+type Finding struct {
+	// Rule names the rule that matched
+	Rule string
+	// Secret is the matched text
+	Secret string
+	// Start and End are the byte offsets of Secret in the scanned text;
+	// they are unset for .env values found by RedactDiff
+	Start, End int
+	// File and Line locate the secret in a file scanned with ScanFile
+	File string
+	Line int
+}
+
+// Scanner detects secrets with the default rules and any custom patterns
+type Scanner struct {
+	rules []Rule
+}
+
+// NewScanner creates a scanner using DefaultRules and custom patterns by
+// name. A pattern with a capture group treats its first group as the secret.
+func NewScanner(patterns map[string]string) (*Scanner, error) {
+	rules := append([]Rule(nil), DefaultRules...)
+
+	// Sort for stable rule order
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pattern, err := regexp.Compile(patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %s: %w", name, err)
+		}
+		rule := Rule{Name: name, Pattern: pattern}
+		if pattern.NumSubexp() > 0 {
+			rule.Group = 1
+		}
+		rules = append(rules, rule)
+	}
+
+	return &Scanner{rules: rules}, nil
+}
+
+// Find returns the secrets in text ordered by position. Where matches of
+// several rules overlap, the one starting first is kept.
+func (s *Scanner) Find(text string) []Finding {
+	var findings []Finding
+	for _, rule := range s.rules {
+		for _, match := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[2*rule.Group], match[2*rule.Group+1]
+			if start < 0 {
+				continue
+			}
+			secret := text[start:end]
+			if rule.MinEntropy > 0 && Entropy(secret) < rule.MinEntropy {
+				continue
+			}
+			if rule.Valid != nil && !rule.Valid(secret) {
+				continue
+			}
+			findings = append(findings, Finding{Rule: rule.Name, Secret: secret, Start: start, End: end})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Start != findings[j].Start {
+			return findings[i].Start < findings[j].Start
+		}
+		return findings[i].End > findings[j].End
+	})
+
+	kept := findings[:0]
+	for _, finding := range findings {
+		if len(kept) > 0 && finding.Start < kept[len(kept)-1].End {
+			continue
+		}
+		kept = append(kept, finding)
+	}
+	return kept
+}
+
+// Redact replaces each secret in text with a placeholder naming its rule
+func (s *Scanner) Redact(text string) (string, []Finding) {
+	findings := s.Find(text)
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, finding := range findings {
+		b.WriteString(text[last:finding.Start])
+		b.WriteString(Placeholder(finding.Rule))
+		last = finding.End
+	}
+	b.WriteString(text[last:])
+	return b.String(), findings
+}
+
+// RedactDiff redacts a unified diff. In addition to Redact, every value
+// added or removed in a .env file is replaced.
+func (s *Scanner) RedactDiff(diff string) (string, []Finding) {
+	diff, findings := s.Redact(diff)
+
+	lines := strings.SplitAfter(diff, "\n")
+	dotenv := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			dotenv = false
+		case strings.HasPrefix(line, "+++ "):
+			dotenv = IsDotenv(strings.TrimSpace(strings.TrimPrefix(line, "+++ ")))
+		case dotenv && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) && !strings.HasPrefix(line, "---"):
+			redacted, finding, ok := redactDotenvLine(line)
+			if ok {
+				lines[i] = redacted
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return strings.Join(lines, ""), findings
+}
+
+// ScanFile returns the secrets in a file's content with their line
+// numbers. Every value in a .env file counts as a secret.
+func (s *Scanner) ScanFile(file, content string) []Finding {
+	if IsDotenv(file) {
+		var findings []Finding
+		for i, line := range strings.Split(content, "\n") {
+			if _, finding, ok := redactDotenvLine("+" + line); ok {
+				finding.File, finding.Line = file, i+1
+				findings = append(findings, finding)
+			}
+		}
+		return findings
+	}
+
+	findings := s.Find(content)
+	for i := range findings {
+		findings[i].File = file
+		findings[i].Line = strings.Count(content[:findings[i].Start], "\n") + 1
+	}
+	return findings
+}
+
+// dotenvExamples are suffixes of .env files meant to be committed with placeholder values
+var dotenvExamples = []string{".example", ".sample", ".template", ".dist"}
+
+// IsDotenv reports whether a file, possibly with a diff's a/ or b/ prefix,
+// is a .env file other than an example
+func IsDotenv(file string) bool {
+	base := path.Base(file)
+	if base != ".env" && !strings.HasPrefix(base, ".env.") {
+		return false
+	}
+	for _, suffix := range dotenvExamples {
+		if strings.HasSuffix(base, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// redactDotenvLine replaces the value of a KEY=VALUE line
+func redactDotenvLine(line string) (string, Finding, bool) {
+	eq := strings.IndexByte(line, '=')
+	if eq < 0 || strings.HasPrefix(strings.TrimSpace(line[1:]), "#") {
+		return line, Finding{}, false
+	}
+	value := strings.TrimSpace(line[eq+1:])
+	if value == "" || strings.Contains(value, "[REDACTED") {
+		return line, Finding{}, false
+	}
+
+	newline := ""
+	if strings.HasSuffix(line, "\n") {
+		newline = "\n"
+	}
+	return line[:eq+1] + Placeholder(dotenvRule) + newline, Finding{Rule: dotenvRule, Secret: value}, true
+}
+
+// Placeholder is the text a redacted secret is replaced with
+func Placeholder(rule string) string {
+	return "[REDACTED " + rule + "]"
+}
+
+// Rules returns the distinct rule names of findings in order of first appearance
+func Rules(findings []Finding) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, finding := range findings {
+		if !seen[finding.Rule] {
+			seen[finding.Rule] = true
+			names = append(names, finding.Rule)
+		}
+	}
+	return names
+}
+
+// notIdentifier reports whether s contains more than letters, underscores
+// and dots, so that assignments like password = cfg.Password are skipped
+func notIdentifier(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == '.')
+	}) >= 0
+}
+
+// Entropy returns the Shannon entropy of s in bits per character
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len([]rune(s)))
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}